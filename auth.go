@@ -30,7 +30,12 @@ func (conn Conn) getAdditionalHeaderKeys(req *http.Request) ([]string, map[strin
 		srcKeys[strings.ToLower(k)] = ""
 	}
 
-	for _, v := range conn.config.AdditionalHeaders {
+	additionalHeaders := conn.config.AdditionalHeaders
+	if len(additionalHeaders) > 0 {
+		// the Idempotency-Key is signed along with the other additional headers
+		additionalHeaders = append(additionalHeaders[:len(additionalHeaders):len(additionalHeaders)], HTTPHeaderIdempotencyKey)
+	}
+	for _, v := range additionalHeaders {
 		if _, ok := srcKeys[strings.ToLower(v)]; ok {
 			keysMap[strings.ToLower(v)] = ""
 		}
@@ -43,9 +48,8 @@ func (conn Conn) getAdditionalHeaderKeys(req *http.Request) ([]string, map[strin
 	return keysList, keysMap
 }
 
-// signHeader signs the header and sets it as the authorization header.
-func (conn Conn) signHeader(req *http.Request) {
-	akIf := conn.config.GetCredentials()
+// signHeader signs the header with akIf and sets it as the authorization header.
+func (conn Conn) signHeader(req *http.Request, akIf Credentials) {
 	authorizationStr := ""
 	if conn.config.AuthVersion == AuthV2 {
 		additionalList, _ := conn.getAdditionalHeaderKeys(req)
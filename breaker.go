@@ -0,0 +1,245 @@
+package x_http_client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects requests until OpenTimeout elapses
+	CircuitOpen
+	// CircuitHalfOpen lets a few trial requests through
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// ErrCircuitOpen is matched by errors.Is for requests rejected by an open circuit breaker
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned without sending the request while the circuit of Key is open
+type CircuitOpenError struct {
+	Key        string        // Endpoint, followed by the path prefix when PathPrefixes matched
+	RetryAfter time.Duration // Time left before the circuit turns half-open
+}
+
+// Error implements interface error
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open: Key=%s, RetryAfter=%s", e.Key, e.RetryAfter)
+}
+
+// Is makes errors.Is(err, ErrCircuitOpen) true
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreakerConfig defines when circuit breakers trip and recover.
+// A breaker is kept per endpoint, and per path prefix when PathPrefixes is set.
+type CircuitBreakerConfig struct {
+	ConsecutiveFailures int           // Trip after this many consecutive failures, 0 disables
+	FailureRate         float64       // Trip when the failure rate over Window reaches it (0-1], 0 disables
+	MinRequests         int           // Requests needed in Window before FailureRate applies
+	Window              time.Duration // Interval failure rates are counted over
+	OpenTimeout         time.Duration // Time the circuit stays open before trial requests
+	HalfOpenRequests    int           // Trial requests allowed half-open, all must succeed to close
+
+	PathPrefixes []string // Path prefixes keyed separately, the longest match wins
+
+	OnStateChange func(key string, from, to CircuitState) // Called on every transition, must not block
+}
+
+// circuitBreakers holds the breakers by key
+type circuitBreakers struct {
+	config   *CircuitBreakerConfig
+	lock     sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newCircuitBreakers(config *CircuitBreakerConfig) *circuitBreakers {
+	if config == nil {
+		return nil
+	}
+	return &circuitBreakers{config: config, breakers: make(map[string]*circuitBreaker)}
+}
+
+// get returns the breaker of endpoint and path, nil when circuit breaking is disabled
+func (cbs *circuitBreakers) get(endpoint, path string) *circuitBreaker {
+	if cbs == nil {
+		return nil
+	}
+
+	key := endpoint
+	prefix := ""
+	for _, p := range cbs.config.PathPrefixes {
+		if strings.HasPrefix(path, p) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	key += prefix
+
+	cbs.lock.Lock()
+	defer cbs.lock.Unlock()
+	cb, ok := cbs.breakers[key]
+	if !ok {
+		cb = &circuitBreaker{key: key, config: cbs.config, windowStart: time.Now()}
+		cbs.breakers[key] = cb
+	}
+	return cb
+}
+
+// circuitBreaker is one closed/open/half-open state machine
+type circuitBreaker struct {
+	key    string
+	config *CircuitBreakerConfig
+
+	lock             sync.Mutex
+	state            CircuitState
+	openedAt         time.Time
+	consecutive      int // consecutive failures
+	requests         int // requests in the current window
+	failures         int // failures in the current window
+	windowStart      time.Time
+	halfOpenInFlight int
+	halfOpenSuccess  int
+	changes          []circuitChange // transitions notified once the lock is released
+}
+
+// circuitChange is one state transition waiting for OnStateChange
+type circuitChange struct {
+	from, to CircuitState
+}
+
+// allow returns a *CircuitOpenError when the request must not be sent
+func (cb *circuitBreaker) allow() error {
+	if cb == nil {
+		return nil
+	}
+	cb.lock.Lock()
+	defer cb.unlock()
+
+	if cb.state == CircuitOpen {
+		left := cb.config.OpenTimeout - time.Since(cb.openedAt)
+		if left > 0 {
+			return &CircuitOpenError{Key: cb.key, RetryAfter: left}
+		}
+		cb.setState(CircuitHalfOpen)
+	}
+	if cb.state == CircuitHalfOpen {
+		if cb.halfOpenInFlight >= cb.halfOpenRequests() {
+			return &CircuitOpenError{Key: cb.key}
+		}
+		cb.halfOpenInFlight++
+	}
+	return nil
+}
+
+// report records the outcome of an allowed request
+func (cb *circuitBreaker) report(failed bool) {
+	if cb == nil {
+		return
+	}
+	cb.lock.Lock()
+	defer cb.unlock()
+
+	switch cb.state {
+	case CircuitHalfOpen:
+		cb.halfOpenInFlight--
+		if failed {
+			cb.trip()
+			return
+		}
+		cb.halfOpenSuccess++
+		if cb.halfOpenSuccess >= cb.halfOpenRequests() {
+			cb.setState(CircuitClosed)
+		}
+	case CircuitClosed:
+		if cb.config.Window > 0 && time.Since(cb.windowStart) > cb.config.Window {
+			cb.windowStart = time.Now()
+			cb.requests, cb.failures = 0, 0
+		}
+		cb.requests++
+		if !failed {
+			cb.consecutive = 0
+			return
+		}
+		cb.failures++
+		cb.consecutive++
+
+		if cb.config.ConsecutiveFailures > 0 && cb.consecutive >= cb.config.ConsecutiveFailures {
+			cb.trip()
+		} else if cb.config.FailureRate > 0 && cb.requests >= cb.config.MinRequests &&
+			float64(cb.failures)/float64(cb.requests) >= cb.config.FailureRate {
+			cb.trip()
+		}
+	}
+}
+
+// release gives back the trial slot of an allowed request whose outcome says nothing about the endpoint,
+// such as a request canceled by the caller
+func (cb *circuitBreaker) release() {
+	if cb == nil {
+		return
+	}
+	cb.lock.Lock()
+	defer cb.unlock()
+	if cb.state == CircuitHalfOpen && cb.halfOpenInFlight > 0 {
+		cb.halfOpenInFlight--
+	}
+}
+
+// unlock releases the lock, then calls OnStateChange for the transitions made while holding it
+func (cb *circuitBreaker) unlock() {
+	changes := cb.changes
+	cb.changes = nil
+	cb.lock.Unlock()
+
+	if cb.config.OnStateChange == nil {
+		return
+	}
+	for _, change := range changes {
+		cb.config.OnStateChange(cb.key, change.from, change.to)
+	}
+}
+
+func (cb *circuitBreaker) halfOpenRequests() int {
+	if cb.config.HalfOpenRequests <= 0 {
+		return 1
+	}
+	return cb.config.HalfOpenRequests
+}
+
+func (cb *circuitBreaker) trip() {
+	cb.openedAt = time.Now()
+	cb.setState(CircuitOpen)
+}
+
+// setState resets the counters of the new state and queues the OnStateChange notification
+func (cb *circuitBreaker) setState(state CircuitState) {
+	from := cb.state
+	cb.state = state
+	cb.consecutive, cb.requests, cb.failures = 0, 0, 0
+	cb.windowStart = time.Now()
+	cb.halfOpenInFlight, cb.halfOpenSuccess = 0, 0
+
+	if from != state && cb.config.OnStateChange != nil {
+		cb.changes = append(cb.changes, circuitChange{from: from, to: state})
+	}
+}
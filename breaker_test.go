@@ -0,0 +1,122 @@
+package x_http_client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy int32
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var transitions []string
+	client, err := New(server.URL, "id", "secret", CircuitBreaker(CircuitBreakerConfig{
+		ConsecutiveFailures: 2,
+		OpenTimeout:         50 * time.Millisecond,
+		PathPrefixes:        []string{"/quote"},
+		OnStateChange: func(key string, from, to CircuitState) {
+			transitions = append(transitions, key+":"+from.String()+"->"+to.String())
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		client.Conn.Do("GET", "/quote/usd", nil, nil, nil, nil)
+	}
+	_, err = client.Conn.Do("GET", "/quote/eur", nil, nil, nil, nil)
+	if !errors.Is(err, ErrCircuitOpen) || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("expected open circuit, got %v after %d hits", err, hits)
+	}
+	if _, err = client.Conn.Do("GET", "/account", nil, nil, nil, nil); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("other path prefix shares the open circuit")
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	if _, err = client.Conn.Do("GET", "/quote/usd", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	key := server.URL + "/quote"
+	want := []string{key + ":closed->open", key + ":open->half-open", key + ":half-open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions %v", transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transitions %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestCircuitBreakerIgnoresCanceledRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	var client *Client
+	unlocked := true
+	client, err := New(server.URL, "id", "secret", CircuitBreaker(CircuitBreakerConfig{
+		ConsecutiveFailures: 1,
+		OpenTimeout:         20 * time.Millisecond,
+		OnStateChange: func(key string, from, to CircuitState) {
+			// the breaker lock is released before notifying
+			cb := client.Conn.breakers.get(server.URL, "/")
+			if cb.lock.TryLock() {
+				cb.lock.Unlock()
+			} else {
+				unlocked = false
+			}
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Config.RetryTimes = 0
+
+	client.Conn.Do("GET", "/fail", nil, nil, nil, nil)
+	time.Sleep(30 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Conn.DoWithContext(ctx, "GET", "/slow", nil, nil, nil, nil); err == nil {
+		t.Fatal("expected the request to be canceled")
+	}
+
+	cb := client.Conn.breakers.get(server.URL, "/")
+	cb.lock.Lock()
+	state, inFlight := cb.state, cb.halfOpenInFlight
+	cb.lock.Unlock()
+	if state != CircuitHalfOpen || inFlight != 0 {
+		t.Fatalf("canceled request left the circuit %s with %d trial requests", state, inFlight)
+	}
+	ep := client.Conn.endpoints.endpoints[0]
+	ep.lock.Lock()
+	failures := ep.failures
+	ep.lock.Unlock()
+	if failures != 1 {
+		t.Fatalf("canceled request reset the endpoint failures to %d", failures)
+	}
+	if !unlocked {
+		t.Fatal("OnStateChange called while holding the breaker lock")
+	}
+}
@@ -0,0 +1,401 @@
+package x_http_client
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a response kept by a CacheStorage
+type CachedResponse struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+	Expires    time.Time         // End of freshness, the response is revalidated after it
+	Vary       map[string]string // Request header values selected by the Vary response header
+}
+
+// CacheStorage keeps cached responses by key
+type CacheStorage interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+	Delete(key string)
+}
+
+// responseCache serves GET requests from storage following the HTTP cache semantics of a private cache
+type responseCache struct {
+	storage CacheStorage
+}
+
+func newResponseCache(storage CacheStorage) *responseCache {
+	if storage == nil {
+		return nil
+	}
+	return &responseCache{storage: storage}
+}
+
+// key identifies the request, the credentials identity keeps tenants apart and the endpoints keep services apart.
+// All endpoints of the client share the entries, they serve the same service.
+func (rc *responseCache) key(conn Conn, req *request) string {
+	identity := sha256.New()
+	identity.Write([]byte(conn.config.GetCredentials().GetAccessAppID()))
+	for _, ep := range conn.endpoints.endpoints {
+		identity.Write([]byte("\n" + ep.URL))
+	}
+	return hex.EncodeToString(identity.Sum(nil)[:8]) + " " + req.path + "?" + req.urlParams
+}
+
+// varyValues returns the request header values named by the Vary header of the response
+func varyValues(headers http.Header, req *request) map[string]string {
+	values := make(map[string]string)
+	for _, vary := range headers.Values(HTTPHeaderVary) {
+		for _, name := range strings.Split(vary, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" {
+				values[name] = headerValue(req.headers, name)
+			}
+		}
+	}
+	return values
+}
+
+// matches reports whether the request selects the same variant as the cached one
+func (cr *CachedResponse) matches(req *request) bool {
+	for name, value := range cr.Vary {
+		if name == "*" || headerValue(req.headers, name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sendCached serves req from the cache when fresh, revalidates it when stale and stores cacheable responses.
+// Successful unsafe requests invalidate the cached GET of the same path.
+func (conn Conn) sendCached(req *request) (*Response, error) {
+	rc := conn.cache
+	key := rc.key(conn, req)
+
+	if req.method != string(HTTPGet) {
+		resp, err := conn.sendUncached(req)
+		if err == nil && req.method != string(HTTPHead) {
+			rc.storage.Delete(key)
+		}
+		return resp, err
+	}
+
+	reqCacheControl := parseCacheControl(headerValue(req.headers, HTTPHeaderCacheControl))
+	if _, ok := reqCacheControl["no-store"]; ok {
+		return conn.sendUncached(req)
+	}
+
+	cached, ok := rc.storage.Get(key)
+	ok = ok && cached.matches(req)
+	if ok {
+		_, noCache := reqCacheControl["no-cache"]
+		if !noCache && time.Now().Before(cached.Expires) {
+			conn.config.WriteLog(Debug, "[Cache]hit %s\n", req.path)
+			return cached.response(), nil
+		}
+
+		// revalidate, the conditional headers are signed with the request
+		etag := cached.Headers.Get(HTTPHeaderEtag)
+		lastModified := cached.Headers.Get(HTTPHeaderLastModified)
+		if etag != "" || lastModified != "" {
+			headers := make(map[string]string, len(req.headers)+2)
+			for k, v := range req.headers {
+				headers[k] = v
+			}
+			if etag != "" {
+				headers[HTTPHeaderIfNoneMatch] = etag
+			}
+			if lastModified != "" {
+				headers[HTTPHeaderIfModifiedSince] = lastModified
+			}
+			conditional := *req
+			conditional.headers = headers
+			req = &conditional
+		}
+	}
+
+	resp, err := conn.sendUncached(req)
+	if resp == nil {
+		return resp, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Close()
+		for k, v := range resp.Headers {
+			cached.Headers[k] = v
+		}
+		cached.Expires = cacheExpires(cached.Headers, time.Now())
+		rc.storage.Set(key, cached)
+		conn.config.WriteLog(Debug, "[Cache]revalidated %s\n", req.path)
+		return cached.response(), nil
+	}
+
+	if err != nil || resp.StatusCode != http.StatusOK || !isCacheable(resp.Headers) {
+		return resp, err
+	}
+	// bodies over Config.CacheMaxBodySize are streamed uncached instead of read into memory
+	maxBody := conn.config.CacheMaxBodySize
+	length, lengthErr := strconv.ParseInt(resp.Headers.Get(HTTPHeaderContentLength), 10, 64)
+	if maxBody > 0 && lengthErr == nil && length > maxBody {
+		return resp, nil
+	}
+
+	var reader io.Reader = resp.Body
+	if maxBody > 0 {
+		reader = io.LimitReader(resp.Body, maxBody+1)
+	}
+	body, readErr := ioutil.ReadAll(reader)
+	if readErr != nil {
+		resp.Close()
+		return nil, readErr
+	}
+	if maxBody > 0 && int64(len(body)) > maxBody {
+		// a body of unknown length is too large, the rest is streamed uncached
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Close()
+	entry := &CachedResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Headers.Clone(),
+		Body:       body,
+		Expires:    cacheExpires(resp.Headers, time.Now()),
+		Vary:       varyValues(resp.Headers, req),
+	}
+	rc.storage.Set(key, entry)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// response builds a Response served from the cache
+func (cr *CachedResponse) response() *Response {
+	return &Response{
+		StatusCode: cr.StatusCode,
+		RequestID:  cr.Headers.Get(HTTPHeaderRequestID),
+		TrackID:    cr.Headers.Get(HTTPHeaderTrackID),
+		Headers:    cr.Headers.Clone(),
+		Body:       ioutil.NopCloser(bytes.NewReader(cr.Body)),
+		FromCache:  true,
+	}
+}
+
+// isCacheable reports whether a 200 response may be stored: not no-store and either fresh for a while or revalidatable
+func isCacheable(headers http.Header) bool {
+	cc := parseCacheControl(headers.Get(HTTPHeaderCacheControl))
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if _, ok := cc["max-age"]; ok {
+		return true
+	}
+	return headers.Get(HTTPHeaderExpires) != "" || headers.Get(HTTPHeaderEtag) != "" ||
+		headers.Get(HTTPHeaderLastModified) != ""
+}
+
+// cacheExpires computes the end of freshness from Cache-Control max-age, or Expires, less the Age
+func cacheExpires(headers http.Header, now time.Time) time.Time {
+	cc := parseCacheControl(headers.Get(HTTPHeaderCacheControl))
+	if _, ok := cc["no-cache"]; ok {
+		return now
+	}
+
+	age := time.Duration(0)
+	if seconds, err := strconv.ParseInt(headers.Get(HTTPHeaderAge), 10, 64); err == nil {
+		age = time.Duration(seconds) * time.Second
+	}
+	if maxAge, ok := cc["max-age"]; ok {
+		seconds, err := strconv.ParseInt(maxAge, 10, 64)
+		if err != nil {
+			return now
+		}
+		return now.Add(time.Duration(seconds)*time.Second - age)
+	}
+	if expires, err := http.ParseTime(headers.Get(HTTPHeaderExpires)); err == nil {
+		if date, err := http.ParseTime(headers.Get(HTTPHeaderDate)); err == nil {
+			// Expires is relative to the server clock
+			return now.Add(expires.Sub(date) - age)
+		}
+		return expires
+	}
+	return now
+}
+
+// parseCacheControl parses the directives of a Cache-Control value
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, arg = part[:i], strings.Trim(part[i+1:], `"`)
+		}
+		directives[strings.ToLower(name)] = arg
+	}
+	return directives
+}
+
+// headerValue looks up a header case-insensitively in a request header map
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// memoryCache is an in-memory LRU CacheStorage capped by the size of its entries
+type memoryCache struct {
+	lock     sync.Mutex
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element
+	lru      *list.List
+}
+
+type memoryCacheEntry struct {
+	key  string
+	resp *CachedResponse
+	size int64
+}
+
+// NewMemoryCache returns an in-memory CacheStorage evicting the least recently used entries over maxBytes, 64MB when 0
+func NewMemoryCache(maxBytes int64) CacheStorage {
+	if maxBytes <= 0 {
+		maxBytes = 64 * 1024 * 1024
+	}
+	return &memoryCache{maxBytes: maxBytes, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// cacheEntrySize approximates the memory held by an entry: its key, body and headers
+func cacheEntrySize(key string, resp *CachedResponse) int64 {
+	size := len(key) + len(resp.Body)
+	for name, values := range resp.Headers {
+		size += len(name)
+		for _, v := range values {
+			size += len(v)
+		}
+	}
+	for name, value := range resp.Vary {
+		size += len(name) + len(value)
+	}
+	return int64(size)
+}
+
+func (mc *memoryCache) Get(key string) (*CachedResponse, bool) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	elem, ok := mc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	mc.lru.MoveToFront(elem)
+	resp := *elem.Value.(*memoryCacheEntry).resp
+	resp.Headers = resp.Headers.Clone()
+	return &resp, true
+}
+
+func (mc *memoryCache) Set(key string, resp *CachedResponse) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	if elem, ok := mc.entries[key]; ok {
+		mc.remove(elem)
+	}
+	size := cacheEntrySize(key, resp)
+	if size > mc.maxBytes {
+		return
+	}
+	mc.entries[key] = mc.lru.PushFront(&memoryCacheEntry{key: key, resp: resp, size: size})
+	mc.size += size
+	for mc.size > mc.maxBytes {
+		mc.remove(mc.lru.Back())
+	}
+}
+
+func (mc *memoryCache) remove(elem *list.Element) {
+	entry := elem.Value.(*memoryCacheEntry)
+	mc.lru.Remove(elem)
+	delete(mc.entries, entry.key)
+	mc.size -= entry.size
+}
+
+func (mc *memoryCache) Delete(key string) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	if elem, ok := mc.entries[key]; ok {
+		mc.remove(elem)
+	}
+}
+
+// diskCache stores one gob file per entry in a directory
+type diskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a CacheStorage keeping entries as files in dir
+func NewDiskCache(dir string) (CacheStorage, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &diskCache{dir: dir}, nil
+}
+
+func (dc *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dc.dir, hex.EncodeToString(sum[:]))
+}
+
+func (dc *diskCache) Get(key string) (*CachedResponse, bool) {
+	fd, err := os.Open(dc.path(key))
+	if err != nil {
+		return nil, false
+	}
+	defer fd.Close()
+
+	var resp CachedResponse
+	if err := gob.NewDecoder(fd).Decode(&resp); err != nil {
+		return nil, false
+	}
+	return &resp, true
+}
+
+func (dc *diskCache) Set(key string, resp *CachedResponse) {
+	fd, err := ioutil.TempFile(dc.dir, TempFilePrefix)
+	if err != nil {
+		return
+	}
+	err = gob.NewEncoder(fd).Encode(resp)
+	fd.Close()
+	if err != nil {
+		os.Remove(fd.Name())
+		return
+	}
+	// rename is atomic, readers never see a partial entry
+	if os.Rename(fd.Name(), dc.path(key)) != nil {
+		os.Remove(fd.Name())
+	}
+}
+
+func (dc *diskCache) Delete(key string) {
+	os.Remove(dc.path(key))
+}
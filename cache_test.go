@@ -0,0 +1,222 @@
+package x_http_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCacheMaxAge(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set(HTTPHeaderCacheControl, "max-age=60")
+		w.Write([]byte("USD,EUR"))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", Cache(NewMemoryCache(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Conn.Do("GET", "/currencies", nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetBodyText() != "USD,EUR" || resp.FromCache != (i > 0) {
+			t.Fatalf("request %d: unexpected response %q, from cache %t", i, resp.GetBodyText(), resp.FromCache)
+		}
+		resp.Close()
+	}
+	if hits != 1 {
+		t.Fatalf("expected 1 request to the server, got %d", hits)
+	}
+
+	// an unsafe request invalidates the entry
+	resp, err := client.Conn.Do("PUT", "/currencies", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+	resp, err = client.Conn.Do("GET", "/currencies", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+	if resp.FromCache || hits != 3 {
+		t.Fatalf("expected the entry to be invalidated, got %d requests", hits)
+	}
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	var revalidated int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HTTPHeaderIfNoneMatch) == `"v1"` {
+			if r.Header.Get(HTTPHeaderAuthorization) == "" {
+				t.Error("conditional request not signed")
+			}
+			atomic.AddInt32(&revalidated, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(HTTPHeaderEtag, `"v1"`)
+		w.Header().Set(HTTPHeaderCacheControl, "no-cache")
+		w.Write([]byte("fees"))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", Cache(NewMemoryCache(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Conn.Do("GET", "/fees", nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.GetBodyText() != "fees" {
+			t.Fatalf("request %d: unexpected response %d %q", i, resp.StatusCode, resp.GetBodyText())
+		}
+		resp.Close()
+	}
+	if revalidated != 1 {
+		t.Fatalf("expected 1 revalidation, got %d", revalidated)
+	}
+}
+
+func TestCacheTenants(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HTTPHeaderCacheControl, "max-age=60")
+		w.Write([]byte("tenant"))
+	}))
+	defer server.Close()
+
+	storage := NewMemoryCache(0)
+	for _, id := range []string{"tenant-a", "tenant-b"} {
+		client, err := New(server.URL, id, "secret", Cache(storage))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Conn.Do("GET", "/profile", nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Close()
+		if resp.FromCache {
+			t.Fatalf("%s served the entry of another tenant", id)
+		}
+	}
+}
+
+func TestCacheServices(t *testing.T) {
+	newService := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HTTPHeaderCacheControl, "max-age=60")
+			w.Write([]byte(body))
+		}))
+	}
+	rates, fees := newService("rates"), newService("fees")
+	defer rates.Close()
+	defer fees.Close()
+
+	storage := NewMemoryCache(0)
+	for _, service := range []struct {
+		server *httptest.Server
+		body   string
+	}{{rates, "rates"}, {fees, "fees"}, {rates, "rates"}} {
+		client, err := New(service.server.URL, "id", "secret", Cache(storage))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Conn.Do("GET", "/list", nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := resp.GetBodyText(); body != service.body {
+			t.Fatalf("%s served %q from the shared cache", service.server.URL, body)
+		}
+	}
+}
+
+func TestCacheMaxBodySize(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set(HTTPHeaderCacheControl, "max-age=60")
+		switch r.URL.Path {
+		case "/small":
+			w.Write([]byte("fees"))
+		case "/large":
+			w.Write([]byte(strings.Repeat("x", 64)))
+		case "/chunked":
+			// flushing before the end leaves the length unknown
+			w.Write([]byte(strings.Repeat("x", 32)))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(strings.Repeat("x", 32)))
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", Cache(NewMemoryCache(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Config.CacheMaxBodySize = 16
+
+	for _, test := range []struct {
+		path   string
+		body   string
+		cached bool
+	}{
+		{"/small", "fees", true},
+		{"/large", strings.Repeat("x", 64), false},
+		{"/chunked", strings.Repeat("x", 64), false},
+	} {
+		atomic.StoreInt32(&hits, 0)
+		for i := 0; i < 2; i++ {
+			resp, err := client.Conn.Do("GET", test.path, nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body := resp.GetBodyText(); body != test.body {
+				t.Fatalf("%s: unexpected body %q", test.path, body)
+			}
+			resp.Close()
+		}
+		if want := map[bool]int32{true: 1, false: 2}[test.cached]; hits != want {
+			t.Fatalf("%s: expected %d requests to the server, got %d", test.path, want, hits)
+		}
+	}
+}
+
+func TestMemoryCacheMaxBytes(t *testing.T) {
+	storage := NewMemoryCache(100)
+	entry := func(size int) *CachedResponse {
+		return &CachedResponse{StatusCode: http.StatusOK, Headers: http.Header{}, Body: make([]byte, size)}
+	}
+
+	storage.Set("a", entry(40))
+	storage.Set("b", entry(40))
+	storage.Get("a")
+	// c does not fit with both, b is the least recently used
+	storage.Set("c", entry(40))
+	for key, kept := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := storage.Get(key); ok != kept {
+			t.Fatalf("entry %s kept %t", key, ok)
+		}
+	}
+
+	// an entry over the cap is not stored and evicts nothing
+	storage.Set("d", entry(200))
+	if _, ok := storage.Get("d"); ok {
+		t.Fatal("entry over the cap stored")
+	}
+	if _, ok := storage.Get("a"); !ok {
+		t.Fatal("entry evicted by one over the cap")
+	}
+}
@@ -3,6 +3,8 @@ package x_http_client
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 type (
@@ -24,15 +26,8 @@ func New(endpoint, accessAppID, accessAppSecret string, options ...ClientOption)
 	config.AccessAppID = accessAppID
 	config.AccessAppSecret = accessAppSecret
 
-	// URL parse
-	url := &urlMaker{}
-	err := url.Init(config.Endpoint)
-	if err != nil {
-		return nil, err
-	}
-
 	// HTTP connect
-	conn := &Conn{config: config, url: url}
+	conn := &Conn{config: config}
 
 	// OSS client
 	client := &Client{
@@ -49,8 +44,203 @@ func New(endpoint, accessAppID, accessAppSecret string, options ...ClientOption)
 		return nil, fmt.Errorf("Init client Error, invalid Auth version: %v", config.AuthVersion)
 	}
 
+	defaults := &Request{}
+	for _, option := range config.RequestOptions {
+		option(defaults)
+	}
+	if defaults.Body != nil || defaults.Listener != nil {
+		return nil, fmt.Errorf("Init client Error, request defaults can't set a body or a progress listener")
+	}
+
+	// URL parse
+	endpoints, err := newEndpointPool(config)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create HTTP connection
-	err = conn.init(config, url, client.HTTPClient)
+	err = conn.init(config, endpoints, client.HTTPClient)
 
 	return client, err
 }
+
+// Endpoints sets the endpoints requests are spread over, replacing the endpoint passed to New
+func Endpoints(endpoints ...Endpoint) ClientOption {
+	return func(client *Client) {
+		client.Config.Endpoints = endpoints
+	}
+}
+
+// EndpointSelection sets how endpoints are selected and how many consecutive failures eject an endpoint for cooldown
+func EndpointSelection(selector EndpointSelector, failureThreshold int, cooldown time.Duration) ClientOption {
+	return func(client *Client) {
+		client.Config.EndpointSelector = selector
+		client.Config.EndpointFailureThreshold = failureThreshold
+		client.Config.EndpointCooldown = cooldown
+	}
+}
+
+// CircuitBreaker enables circuit breaking per endpoint
+func CircuitBreaker(config CircuitBreakerConfig) ClientOption {
+	return func(client *Client) {
+		client.Config.CircuitBreaker = &config
+	}
+}
+
+// RateLimits sets client-side rate limits, a limit without PathPattern applies to every request
+func RateLimits(limits ...RateLimit) ClientOption {
+	return func(client *Client) {
+		client.Config.RateLimits = limits
+	}
+}
+
+// Hedging enables hedged GET and HEAD requests
+func Hedging(config HedgingConfig) ClientOption {
+	return func(client *Client) {
+		client.Config.Hedging = &config
+	}
+}
+
+// Cache enables the response cache of GET requests backed by storage, such as NewMemoryCache
+func Cache(storage CacheStorage) ClientOption {
+	return func(client *Client) {
+		client.Config.Cache = storage
+	}
+}
+
+// IdempotencyKeys adds a generated Idempotency-Key header to POST, PUT, PATCH and DELETE requests
+func IdempotencyKeys() ClientOption {
+	return func(client *Client) {
+		client.Config.IdempotencyKeys = true
+	}
+}
+
+// Coalescing merges concurrent identical GET and HEAD requests whose given headers match into one upstream call
+func Coalescing(headers ...string) ClientOption {
+	return func(client *Client) {
+		client.Config.CoalesceRequests = true
+		client.Config.CoalesceHeaders = headers
+	}
+}
+
+// AcceptEncodings sets the response encodings requested and transparently decoded, none when empty
+func AcceptEncodings(encodings ...string) ClientOption {
+	return func(client *Client) {
+		client.Config.AcceptEncodings = encodings
+	}
+}
+
+// RequestCompression sends request bodies of known length from threshold bytes gzip compressed
+func RequestCompression(threshold int64) ClientOption {
+	return func(client *Client) {
+		client.Config.RequestCompressionThreshold = threshold
+	}
+}
+
+// MaxDecompressedSize raises or lowers the 64MB limit of decoded response bodies, such as for large exports, 0 for no limit
+func MaxDecompressedSize(size int64) ClientOption {
+	return func(client *Client) {
+		client.Config.MaxDecompressedSize = size
+	}
+}
+
+// TLS sets the CAs, client certificates and pins of TLS connections
+func TLS(config TLSConfig) ClientOption {
+	return func(client *Client) {
+		client.Config.TLS = &config
+	}
+}
+
+// HTTP2 enables HTTP/2 over TLS, or h2c with config.Cleartext
+func HTTP2(config HTTP2Config) ClientOption {
+	return func(client *Client) {
+		client.Config.HTTP2 = &config
+	}
+}
+
+// ProxyFromEnvironment resolves the proxy from HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+func ProxyFromEnvironment() ClientOption {
+	return func(client *Client) {
+		client.Config.ProxyFromEnvironment = true
+	}
+}
+
+// ProxySelector chooses the proxy of each request, such as by host like a PAC file, nil URL for direct
+func ProxySelector(fn func(req *http.Request) (*url.URL, error)) ClientOption {
+	return func(client *Client) {
+		client.Config.ProxyFunc = fn
+	}
+}
+
+// ProxyAuth sets the provider of proxy credentials
+func ProxyAuth(provider ProxyCredentialsProvider) ClientOption {
+	return func(client *Client) {
+		client.Config.ProxyCredentialsProvider = provider
+	}
+}
+
+// StaticHosts dials the given hosts at fixed addresses without a DNS lookup
+func StaticHosts(hosts map[string][]string) ClientOption {
+	return func(client *Client) {
+		client.Config.StaticHosts = hosts
+	}
+}
+
+// DNSCache reuses resolved addresses for ttl
+func DNSCache(ttl time.Duration) ClientOption {
+	return func(client *Client) {
+		client.Config.DNSCacheTTL = ttl
+	}
+}
+
+// UnixSocket sends every request through the Unix domain socket at path,
+// except the requests to unix:// endpoints which dial the socket of their endpoint
+func UnixSocket(path string) ClientOption {
+	return func(client *Client) {
+		client.Config.UnixSocket = path
+	}
+}
+
+// RequestDefaults sets the options applied to every request before its own options.
+// WithBody and WithProgress are rejected by New, one reader or listener can't be shared by every request.
+func RequestDefaults(options ...RequestOption) ClientOption {
+	return func(client *Client) {
+		client.Config.RequestOptions = options
+	}
+}
+
+// ErrorDecoder decodes the error bodies of every endpoint with decoder
+func ErrorDecoder(decoder ServiceErrorDecoder) ClientOption {
+	return func(client *Client) {
+		client.Config.ErrorDecoder = decoder
+	}
+}
+
+// EnvelopeErrors turns 2xx JSON responses whose numeric envelope code is not a success code into *ServiceError.
+// Bodies up to 1MB are read into memory to be checked, larger ones are returned as is.
+func EnvelopeErrors() ClientOption {
+	return func(client *Client) {
+		client.Config.EnvelopeErrors = true
+	}
+}
+
+// EnvelopeSuccessCodes sets the envelope codes meaning success, only 0 by default
+func EnvelopeSuccessCodes(codes ...int) ClientOption {
+	return func(client *Client) {
+		client.Config.EnvelopeSuccessCodes = codes
+	}
+}
+
+// Redirects follows redirects with policy, signing every hop again
+func Redirects(policy RedirectPolicy) ClientOption {
+	return func(client *Client) {
+		client.Config.Redirect = &policy
+	}
+}
+
+// ClockSkewErrors sets the error codes of requests rejected for their Date, which are sent again once the clock is corrected
+func ClockSkewErrors(codes ...int) ClientOption {
+	return func(client *Client) {
+		client.Config.ClockSkewErrorCodes = codes
+	}
+}
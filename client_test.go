@@ -2,11 +2,13 @@ package x_http_client
 
 import (
 	"fmt"
+	"io/ioutil"
 	logger "log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
-
 )
 
 type ResponseJSON struct {
@@ -14,8 +16,24 @@ type ResponseJSON struct {
 	Msg  interface{} `json:"msg,string"`
 }
 
-func newClient() *Client {
-	client, err := New("127.0.0.1:5001", "1234512345", "")
+// newTestServer starts a server answering like the wallet trade service
+func newTestServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HTTPHeaderAuthorization) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ioutil.ReadAll(r.Body)
+		w.Header().Set(HTTPHeaderContentType, "application/json; charset=utf-8")
+		w.Header().Set(HTTPHeaderRequestID, "req-1")
+		fmt.Fprintf(w, `{"code":0,"msg":%q}`, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newClient(endpoint string) *Client {
+	client, err := New(endpoint, "1234512345", "")
 	client.Config.LogLevel = Debug
 	client.Config.Logger = logger.New(os.Stdout, "", logger.LstdFlags)
 	fmt.Println("newClient:", err)
@@ -23,24 +41,23 @@ func newClient() *Client {
 }
 
 func TestNew(t *testing.T) {
-	client := newClient()
+	client := newClient(newTestServer(t).URL)
 	headers := make(map[string]string)
 	params := make(map[string]interface{})
 	data := strings.NewReader("{\n  \"biz_code\": \"\",\n  \"biz_no\": \"\",\n  \"track_no\": \"\",\n  \"app_id\": 0,\n  \"request_no\": \"\",\n  \"request_ip\": \"\",\n  \"request_uid\": 0,\n  \"request_role\": \"\",\n  \"out_order_no\": \"\",\n  \"acct_uid\": 0,\n  \"title\": \"0\",\n  \"amount\": 0,\n  \"fee\": 0,\n  \"remark\": \"remark\"\n}")
 	json := &ResponseJSON{}
 	resp, err := client.Conn.DoJSONResponse("POST", "/account/trade/withdraw/pre", params, headers, data, json)
-	// body := ""
-	// if err == nil {
-	// 	out, e := ioutil.ReadAll(resp.Body)
-	// 	body = string(out)
-	// 	err = e
-	// }
-	// log.Infof("body:%v", body)
-	// log.Infof("Do:%v,%v,%v", resp.Headers, body, err)
+	if err != nil {
+		t.Fatalf("DoJSONResponse: %v", err)
+	}
+	if resp.BodyJSONError != nil {
+		t.Fatalf("BodyJSONError: %v", resp.BodyJSONError)
+	}
+	if resp.RequestID != "req-1" || resp.GetBodyText() == "" {
+		t.Fatalf("unexpected response %+v", resp.Response)
+	}
 
-	// jsonUnmarshal(resp.Body, json)
 	fmt.Printf("body:%v", json)
 	fmt.Printf("body:%v", json.Msg.(string))
 	fmt.Printf("Do:%v,%v", resp, err)
-
 }
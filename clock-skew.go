@@ -0,0 +1,118 @@
+package x_http_client
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// clockSkewErrorCodes are the textual error codes of requests rejected for their Date
+var clockSkewErrorCodes = map[string]bool{
+	"RequestTimeTooSkewed": true,
+	"RequestExpired":       true,
+	"SignatureExpired":     true,
+}
+
+// ClockSkew returns how far the endpoint clock is ahead of the local clock, measured from the Date of its last response
+func (s *EndpointState) ClockSkew() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.skew))
+}
+
+// ClockOffset returns the correction added to the local clock when signing requests to the endpoint
+func (s *EndpointState) ClockOffset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.offset))
+}
+
+// now returns the corrected time stamped in the Date of requests to the endpoint
+func (s *EndpointState) now() time.Time {
+	if s == nil {
+		return time.Now()
+	}
+	return time.Now().Add(s.ClockOffset())
+}
+
+// measureSkew updates the skew from the Date header of a response received at received.
+// Cached responses, which carry Age and the Date of the origin, are skipped. The offset follows the skew
+// once two measurements in a row differ from it by threshold and agree, a negative threshold never corrects.
+func (s *EndpointState) measureSkew(header http.Header, received time.Time, threshold time.Duration) {
+	if s == nil {
+		return
+	}
+	if age := header.Get(HTTPHeaderAge); age != "" && age != "0" {
+		return
+	}
+	date, err := http.ParseTime(header.Get(HTTPHeaderDate))
+	if err != nil {
+		return
+	}
+	// Date is truncated to the second, take the middle of it
+	skew := date.Add(500 * time.Millisecond).Sub(received)
+	atomic.StoreInt64(&s.skew, int64(skew))
+	if threshold < 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch {
+	case absDuration(skew-s.ClockOffset()) < threshold:
+		s.skewPending = false
+	case s.skewPending && absDuration(skew-s.skewFirst) < threshold:
+		atomic.StoreInt64(&s.offset, int64(skew))
+		s.skewPending = false
+	default:
+		s.skewPending, s.skewFirst = true, skew
+	}
+}
+
+// correctClock applies the measured skew after the endpoint rejected a request signed with offset,
+// it reports whether the correction changed enough to send the request again
+func (conn Conn) correctClock(ep *EndpointState, offset time.Duration) bool {
+	if conn.config.ClockSkewThreshold < 0 {
+		return false
+	}
+	skew := ep.ClockSkew()
+	ep.lock.Lock()
+	atomic.StoreInt64(&ep.offset, int64(skew))
+	ep.skewPending = false
+	ep.lock.Unlock()
+	if absDuration(skew-offset) < time.Second {
+		return false
+	}
+	conn.config.WriteLog(Warn, "[Endpoint:%s]clock skew %s, signing with the corrected time\n", ep.URL, skew)
+	return true
+}
+
+// isClockSkewError reports whether the server rejected the request for its Date
+func (conn Conn) isClockSkewError(err error) bool {
+	var srvErr *ServiceError
+	if !errors.As(err, &srvErr) {
+		return false
+	}
+	if clockSkewErrorCodes[srvErr.ErrorCode] {
+		return true
+	}
+	for _, code := range conn.config.ClockSkewErrorCodes {
+		if srvErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// ClockSkews returns the measured clock skew of every endpoint by URL, for monitoring
+func (conn Conn) ClockSkews() map[string]time.Duration {
+	skews := make(map[string]time.Duration, len(conn.endpoints.endpoints))
+	for _, ep := range conn.endpoints.endpoints {
+		skews[ep.URL] = ep.ClockSkew()
+	}
+	return skews
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
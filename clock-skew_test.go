@@ -0,0 +1,137 @@
+package x_http_client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSkewedServer answers with a clock one hour ahead and rejects requests dated more than 15 minutes away
+func newSkewedServer(t *testing.T, reject func(w http.ResponseWriter)) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		serverNow := time.Now().Add(time.Hour)
+		w.Header().Set(HTTPHeaderDate, serverNow.UTC().Format(http.TimeFormat))
+		date, err := http.ParseTime(r.Header.Get(HTTPHeaderDate))
+		if err != nil || absDuration(serverNow.Sub(date)) > 15*time.Minute {
+			reject(w)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestClockSkewCorrection(t *testing.T) {
+	server, requests := newSkewedServer(t, func(w http.ResponseWriter) {
+		w.Header().Set(HTTPHeaderContentType, "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("<Error><Code>RequestTimeTooSkewed</Code><Message>date too far</Message></Error>"))
+	})
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Conn.Do("POST", "/pay", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := resp.GetBodyText(); body != "ok" || atomic.LoadInt32(requests) != 2 {
+		t.Fatalf("unexpected result %q after %d requests", body, atomic.LoadInt32(requests))
+	}
+	if skew := client.Conn.ClockSkews()[server.URL]; absDuration(skew-time.Hour) > 2*time.Second {
+		t.Fatalf("unexpected skew %s", skew)
+	}
+
+	if _, err := client.Conn.Do("GET", "/balance", nil, nil, nil, nil); err != nil || atomic.LoadInt32(requests) != 3 {
+		t.Fatalf("corrected clock not reused: %v after %d requests", err, atomic.LoadInt32(requests))
+	}
+}
+
+func TestClockSkewErrorCodes(t *testing.T) {
+	server, requests := newSkewedServer(t, func(w http.ResponseWriter) {
+		w.Header().Set(HTTPHeaderContentType, "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":40100,"msg":"signature expired"}`))
+	})
+
+	disabled, err := New(server.URL, "id", "secret", ClockSkewErrors(40100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled.Config.ClockSkewThreshold = -1
+	_, err = disabled.Conn.Do("GET", "/balance", nil, nil, nil, nil)
+	if !errors.Is(err, ErrUnauthorized) || atomic.LoadInt32(requests) != 1 {
+		t.Fatalf("unexpected result %v after %d requests", err, atomic.LoadInt32(requests))
+	}
+
+	client, err := New(server.URL, "id", "secret", ClockSkewErrors(40100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Conn.Do("GET", "/balance", nil, nil, nil, nil); err != nil || atomic.LoadInt32(requests) != 3 {
+		t.Fatalf("unexpected result %v after %d requests", err, atomic.LoadInt32(requests))
+	}
+}
+
+func TestClockSkewMeasurement(t *testing.T) {
+	ahead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HTTPHeaderDate, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		if r.URL.Path == "/cached" {
+			w.Header().Set(HTTPHeaderAge, "3600")
+		}
+	}))
+	defer ahead.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, ahead.URL+"/landing", http.StatusFound)
+	}))
+	defer server.Close()
+
+	// the Date of a redirect target is not the clock of the endpoint
+	redirected, err := New(server.URL, "id", "secret", Redirects(RedirectPolicy{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := redirected.Conn.Do("GET", "/go", nil, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if offset := redirected.Conn.endpoints.endpoints[0].ClockOffset(); offset != 0 {
+		t.Fatalf("offset moved by a redirect target: %s", offset)
+	}
+
+	client, err := New(ahead.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := client.Conn.endpoints.endpoints[0]
+	for i := 0; i < 2; i++ {
+		if _, err := client.Conn.Do("GET", "/cached", nil, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if offset := ep.ClockOffset(); offset != 0 {
+		t.Fatalf("offset moved by cached responses: %s", offset)
+	}
+
+	// one measurement is not enough, two agreeing ones move the offset
+	if _, err := client.Conn.Do("GET", "/fresh", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if offset := ep.ClockOffset(); offset != 0 {
+		t.Fatalf("offset moved by a single measurement: %s", offset)
+	}
+	if _, err := client.Conn.Do("GET", "/fresh", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if offset := ep.ClockOffset(); absDuration(offset-time.Hour) > 2*time.Second {
+		t.Fatalf("unexpected offset %s", offset)
+	}
+}
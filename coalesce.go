@@ -0,0 +1,142 @@
+package x_http_client
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// coalescer merges concurrent identical GET and HEAD requests into one upstream call
+type coalescer struct {
+	headers []string // request headers that are part of the key
+
+	lock  sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is the upstream call shared by the waiting requests, its body is buffered for all of them
+type coalescedCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	resp *Response
+	body []byte
+	err  error
+}
+
+func newCoalescer(enabled bool, headers []string) *coalescer {
+	if !enabled {
+		return nil
+	}
+	return &coalescer{headers: headers, calls: make(map[string]*coalescedCall)}
+}
+
+// applies reports whether req may share an upstream call
+func (c *coalescer) applies(req *request) bool {
+	if c == nil || req.data != nil || req.listener != nil {
+		return false
+	}
+	return req.method == string(HTTPGet) || req.method == string(HTTPHead)
+}
+
+// key identifies the request by method, URL and the selected headers
+func (c *coalescer) key(conn Conn, req *request) (string, bool) {
+	uri, err := conn.endpoints.endpoints[0].url.getURL(req.path, req.urlParams)
+	if err != nil {
+		return "", false
+	}
+	var key strings.Builder
+	key.WriteString(conn.config.GetCredentials().GetAccessAppID())
+	key.WriteString(" " + req.method + " " + uri.String())
+	for _, name := range c.headers {
+		key.WriteString("\n" + http.CanonicalHeaderKey(name) + ": " + headerValue(req.headers, name))
+	}
+	return key.String(), true
+}
+
+// sendCoalesced joins the call in flight for an identical request or starts one.
+// The upstream call is canceled once every waiting request has given up.
+func (conn Conn) sendCoalesced(req *request) (*Response, error) {
+	c := conn.coalescer
+	key, ok := c.key(conn, req)
+	if !ok {
+		return conn.sendAttempt(req)
+	}
+
+	c.lock.Lock()
+	call, found := c.calls[key]
+	if !found {
+		ctx, cancel := context.WithCancel(context.WithoutCancel(req.ctx))
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+
+		upstream := *req
+		upstream.ctx = ctx
+		go c.run(conn, key, call, &upstream)
+	} else {
+		conn.config.WriteLog(Debug, "[Coalesce]%s %s joins the call in flight\n", req.method, req.path)
+	}
+	call.waiters++
+	c.lock.Unlock()
+
+	select {
+	case <-call.done:
+	case <-req.ctx.Done():
+		c.leave(key, call)
+		return nil, req.ctx.Err()
+	}
+	c.leave(key, call)
+
+	if call.resp == nil {
+		return nil, call.err
+	}
+	resp := *call.resp
+	resp.Headers = call.resp.Headers.Clone()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(call.body))
+	return &resp, call.err
+}
+
+// run makes the upstream call and buffers its body
+func (c *coalescer) run(conn Conn, key string, call *coalescedCall, req *request) {
+	defer call.cancel()
+
+	resp, err := conn.sendAttempt(req)
+	if resp != nil && resp.Body != nil {
+		body, readErr := ioutil.ReadAll(resp.Body)
+		resp.Close()
+		if readErr != nil {
+			resp, err = nil, readErr
+		}
+		call.body = body
+	}
+	call.resp, call.err = resp, err
+
+	c.lock.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	c.lock.Unlock()
+	close(call.done)
+}
+
+// leave drops a waiting request, canceling the call when nobody waits for it anymore
+func (c *coalescer) leave(key string, call *coalescedCall) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	select {
+	case <-call.done:
+	default:
+		call.cancel()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+	}
+}
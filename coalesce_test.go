@@ -0,0 +1,55 @@
+package x_http_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescing(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(r.Header.Get(HTTPHeaderAccept)))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", Coalescing(HTTPHeaderAccept))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 20)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			accept := "application/json"
+			if i%2 == 1 {
+				accept = "application/xml"
+			}
+			resp, err := client.Conn.Do("GET", "/rates", nil, map[string]string{HTTPHeaderAccept: accept}, nil, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Close()
+			bodies[i] = resp.GetBodyText()
+		}(i)
+	}
+	wg.Wait()
+
+	if calls != 2 {
+		t.Fatalf("expected one upstream call per Accept value, got %d", calls)
+	}
+	for i, body := range bodies {
+		if (i%2 == 0 && body != "application/json") || (i%2 == 1 && body != "application/xml") {
+			t.Fatalf("request %d: unexpected body %q", i, body)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package x_http_client
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec encodes request bodies and decodes response bodies for one media type.
+type Codec interface {
+	// ContentType returns the media type sent in Content-Type and Accept.
+	ContentType() string
+	// Marshal encodes v into the request body.
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes the response body into v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// ServiceErrorDecoder is implemented by codecs whose error envelope can't be decoded into ServiceError by Unmarshal.
+type ServiceErrorDecoder interface {
+	DecodeServiceError(data []byte, srvErr *ServiceError) error
+}
+
+// CodecAccepter is implemented by codecs that accept more media types than ContentType in responses.
+type CodecAccepter interface {
+	// Accept returns the value of the Accept header.
+	Accept() string
+}
+
+// Built-in codecs
+var (
+	JSONCodec Codec = jsonCodec{}
+	FormCodec Codec = formCodec{}
+	XMLCodec  Codec = xmlCodec{}
+	RawCodec  Codec = rawCodec{}
+)
+
+var (
+	codecsLock sync.RWMutex
+	codecs     = map[string]Codec{}
+)
+
+func init() {
+	RegisterCodec(JSONCodec, "application/json", "text/json")
+	RegisterCodec(FormCodec)
+	RegisterCodec(XMLCodec, "application/xml", "text/xml")
+	RegisterCodec(RawCodec)
+}
+
+// RegisterCodec registers codec for content negotiation of response bodies.
+// The codec is registered under its own ContentType and the given aliases.
+func RegisterCodec(codec Codec, contentTypes ...string) {
+	if len(contentTypes) == 0 {
+		contentTypes = []string{codec.ContentType()}
+	}
+
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	for _, ct := range contentTypes {
+		codecs[mediaType(ct)] = codec
+	}
+}
+
+// CodecForContentType returns the registered codec for a Content-Type header value, or nil if none matches.
+// Structured syntax suffixes such as "application/problem+json" fall back to the codec of the suffix.
+func CodecForContentType(contentType string) Codec {
+	mt := mediaType(contentType)
+	if mt == "" {
+		return nil
+	}
+
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	if codec, ok := codecs[mt]; ok {
+		return codec
+	}
+	if i := strings.LastIndex(mt, "+"); i >= 0 {
+		if codec, ok := codecs["application/"+mt[i+1:]]; ok {
+			return codec
+		}
+	}
+	return nil
+}
+
+// mediaType strips parameters from a Content-Type value and lower cases it
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	}
+	return strings.ToLower(mt)
+}
+
+// acceptHeader returns the Accept header value sent for codec
+func acceptHeader(codec Codec) string {
+	if accepter, ok := codec.(CodecAccepter); ok {
+		return accepter.Accept()
+	}
+	return codec.ContentType()
+}
+
+// encodeBody encodes v into a request body with codec, readers are sent as is
+func encodeBody(codec Codec, v interface{}) (io.Reader, error) {
+	switch body := v.(type) {
+	case nil:
+		return nil, nil
+	case io.Reader:
+		return body, nil
+	}
+
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// jsonCodec encodes with encoding/json
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// xmlCodec encodes with encoding/xml
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml"
+}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// rawCodec passes bytes through untouched
+type rawCodec struct{}
+
+func (rawCodec) ContentType() string {
+	return "application/octet-stream"
+}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch data := v.(type) {
+	case []byte:
+		return data, nil
+	case string:
+		return []byte(data), nil
+	case io.Reader:
+		return ioutil.ReadAll(data)
+	}
+	return nil, fmt.Errorf("raw codec can't marshal %T", v)
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch out := v.(type) {
+	case *[]byte:
+		*out = append((*out)[:0], data...)
+	case *string:
+		*out = string(data)
+	case io.Writer:
+		_, err := out.Write(data)
+		return err
+	default:
+		return fmt.Errorf("raw codec can't unmarshal into %T", v)
+	}
+	return nil
+}
+
+// formCodec encodes application/x-www-form-urlencoded bodies.
+// Maps and url.Values are encoded by key, structs by their `form` tag or field name.
+type formCodec struct{}
+
+func (formCodec) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+func (formCodec) Marshal(v interface{}) ([]byte, error) {
+	values, err := formValues(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (formCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	switch out := v.(type) {
+	case *url.Values:
+		*out = values
+		return nil
+	case *map[string][]string:
+		*out = values
+		return nil
+	case *map[string]string:
+		m := make(map[string]string, len(values))
+		for k := range values {
+			m[k] = values.Get(k)
+		}
+		*out = m
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("form codec can't unmarshal into %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, ok := formFieldName(rt.Field(i))
+		if !ok {
+			continue
+		}
+		if vs, ok := values[name]; ok && len(vs) > 0 {
+			if err := setFormField(rv.Field(i), vs[0]); err != nil {
+				return fmt.Errorf("form codec field %s: %s", name, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func formValues(v interface{}) (url.Values, error) {
+	switch in := v.(type) {
+	case url.Values:
+		return in, nil
+	case map[string][]string:
+		return url.Values(in), nil
+	case map[string]string:
+		values := url.Values{}
+		for k, s := range in {
+			values.Set(k, s)
+		}
+		return values, nil
+	case map[string]interface{}:
+		values := url.Values{}
+		for k, s := range in {
+			values.Set(k, fmt.Sprint(s))
+		}
+		return values, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("form codec can't marshal %T", v)
+	}
+
+	values := url.Values{}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, ok := formFieldName(rt.Field(i))
+		if !ok {
+			continue
+		}
+		values.Set(name, fmt.Sprint(rv.Field(i).Interface()))
+	}
+	return values, nil
+}
+
+// formFieldName returns the form key of an exported struct field, `form:"-"` skips the field
+func formFieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	name := strings.Split(field.Tag.Get("form"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+func setFormField(field reflect.Value, s string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported kind %s", field.Kind())
+	}
+	return nil
+}
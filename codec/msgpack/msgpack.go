@@ -0,0 +1,67 @@
+// Package msgpack provides the application/msgpack codec.
+// Importing the package registers Default for response content negotiation.
+package msgpack
+
+import (
+	"bytes"
+
+	xhttp "github.com/872409/ghttpclient"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ContentType is the media type of MessagePack bodies
+const ContentType = "application/msgpack"
+
+// Codec encodes values with MessagePack
+type Codec struct {
+	// UseJSONTag reads field names from `json` struct tags instead of `msgpack` tags.
+	UseJSONTag bool
+}
+
+// Default MessagePack codec
+var Default = &Codec{}
+
+func init() {
+	xhttp.RegisterCodec(Default, ContentType, "application/x-msgpack", "application/vnd.msgpack")
+}
+
+// ContentType implements xhttp.Codec
+func (c *Codec) ContentType() string {
+	return ContentType
+}
+
+// Accept implements xhttp.CodecAccepter, gateways may still answer errors in JSON
+func (c *Codec) Accept() string {
+	return ContentType + ", application/x-msgpack;q=0.9, application/json;q=0.5"
+}
+
+// Marshal implements xhttp.Codec
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	if c.UseJSONTag {
+		enc.SetCustomStructTag("json")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements xhttp.Codec
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	return decode(data, v, c.UseJSONTag)
+}
+
+// DecodeServiceError implements xhttp.ServiceErrorDecoder, the envelope uses the JSON field names
+func (c *Codec) DecodeServiceError(data []byte, srvErr *xhttp.ServiceError) error {
+	return decode(data, srvErr, true)
+}
+
+func decode(data []byte, v interface{}, useJSONTag bool) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	if useJSONTag {
+		dec.SetCustomStructTag("json")
+	}
+	return dec.Decode(v)
+}
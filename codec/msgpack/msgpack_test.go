@@ -0,0 +1,47 @@
+package msgpack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	xhttp "github.com/872409/ghttpclient"
+)
+
+type quote struct {
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+}
+
+func TestCodecNegotiation(t *testing.T) {
+	codec := &Codec{UseJSONTag: true}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(xhttp.HTTPHeaderContentType, "application/x-msgpack")
+		if r.URL.Path == "/missing" {
+			data, _ := codec.Marshal(map[string]interface{}{"code": 404, "msg": "quote not found"})
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(data)
+			return
+		}
+		data, _ := codec.Marshal(&quote{Symbol: "USD", Price: "1.5"})
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client, err := xhttp.New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out quote
+	resp, err := client.Conn.DoCodecResponse("GET", "/quote", nil, nil, codec, nil, &out)
+	if err != nil || resp.BodyDecodeError != nil || out.Symbol != "USD" {
+		t.Fatalf("DoCodecResponse: %v %v %+v", err, resp.BodyDecodeError, out)
+	}
+
+	_, err = client.Conn.DoCodecResponse("GET", "/missing", nil, nil, codec, nil, &out)
+	srvErr, ok := err.(*xhttp.ServiceError)
+	if !ok || srvErr.Code != 404 || srvErr.Message != "quote not found" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
@@ -0,0 +1,9 @@
+module github.com/872409/ghttpclient/codec/protobuf
+
+go 1.24
+
+require (
+	github.com/872409/ghttpclient v0.1.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250818200422-3122310a409c
+	google.golang.org/protobuf v1.36.12
+)
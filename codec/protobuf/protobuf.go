@@ -0,0 +1,107 @@
+// Package protobuf provides the application/x-protobuf codec.
+// Importing the package registers Default for response content negotiation.
+package protobuf
+
+import (
+	"fmt"
+
+	xhttp "github.com/872409/ghttpclient"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ContentType is the media type of protobuf bodies
+const ContentType = "application/x-protobuf"
+
+// Codec encodes proto.Message values
+type Codec struct {
+	// NewErrorEnvelope returns the message error bodies are decoded into, google.rpc.Status if nil.
+	// Its code, msg or message, track_id, request_id and host_id fields fill the ServiceError.
+	NewErrorEnvelope func() proto.Message
+
+	MarshalOptions   proto.MarshalOptions
+	UnmarshalOptions proto.UnmarshalOptions
+}
+
+// Default protobuf codec
+var Default = &Codec{}
+
+func init() {
+	xhttp.RegisterCodec(Default, ContentType, "application/protobuf", "application/vnd.google.protobuf")
+}
+
+// ContentType implements xhttp.Codec
+func (c *Codec) ContentType() string {
+	return ContentType
+}
+
+// Accept implements xhttp.CodecAccepter, gateways may still answer errors in JSON
+func (c *Codec) Accept() string {
+	return ContentType + ", application/protobuf;q=0.9, application/json;q=0.5"
+}
+
+// Marshal implements xhttp.Codec
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec can't marshal %T, not a proto.Message", v)
+	}
+	return c.MarshalOptions.Marshal(msg)
+}
+
+// Unmarshal implements xhttp.Codec
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf codec can't unmarshal into %T, not a proto.Message", v)
+	}
+	return c.UnmarshalOptions.Unmarshal(data, msg)
+}
+
+// DecodeServiceError implements xhttp.ServiceErrorDecoder
+func (c *Codec) DecodeServiceError(data []byte, srvErr *xhttp.ServiceError) error {
+	var envelope proto.Message = &status.Status{}
+	if c.NewErrorEnvelope != nil {
+		envelope = c.NewErrorEnvelope()
+	}
+	if err := c.UnmarshalOptions.Unmarshal(data, envelope); err != nil {
+		return err
+	}
+
+	m := envelope.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) || fd.IsList() || fd.IsMap() {
+			continue
+		}
+		value := m.Get(fd)
+		switch fd.Name() {
+		case "code":
+			srvErr.Code = intValue(fd, value)
+		case "msg", "message":
+			srvErr.Message = value.String()
+		case "track_id":
+			srvErr.TrackID = value.String()
+		case "request_id":
+			srvErr.RequestID = value.String()
+		case "host_id":
+			srvErr.HostID = value.String()
+		}
+	}
+	return nil
+}
+
+func intValue(fd protoreflect.FieldDescriptor, value protoreflect.Value) int {
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return int(value.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return int(value.Uint())
+	case protoreflect.EnumKind:
+		return int(value.Enum())
+	}
+	return 0
+}
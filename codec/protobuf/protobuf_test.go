@@ -0,0 +1,48 @@
+package protobuf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	xhttp "github.com/872409/ghttpclient"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodecNegotiation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(xhttp.HTTPHeaderAccept) != Default.Accept() {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set(xhttp.HTTPHeaderContentType, ContentType)
+		if r.URL.Path == "/missing" {
+			data, _ := proto.Marshal(&status.Status{Code: 404, Message: "quote not found"})
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(data)
+			return
+		}
+		data, _ := proto.Marshal(wrapperspb.String("USD"))
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client, err := xhttp.New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &wrapperspb.StringValue{}
+	resp, err := client.Conn.DoCodecResponse("POST", "/quote", nil, nil, Default, wrapperspb.String("q"), out)
+	if err != nil || resp.BodyDecodeError != nil || out.Value != "USD" {
+		t.Fatalf("DoCodecResponse: %v %v %v", err, resp.BodyDecodeError, out)
+	}
+
+	_, err = client.Conn.DoCodecResponse("GET", "/missing", nil, nil, Default, nil, out)
+	srvErr, ok := err.(*xhttp.ServiceError)
+	if !ok || srvErr.Code != 404 || srvErr.Message != "quote not found" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
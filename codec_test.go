@@ -0,0 +1,94 @@
+package x_http_client
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type formPayload struct {
+	Name   string `form:"name"`
+	Amount int64  `form:"amount"`
+	Skip   string `form:"-"`
+}
+
+func TestFormCodec(t *testing.T) {
+	data, err := FormCodec.Marshal(&formPayload{Name: "a b", Amount: 12, Skip: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "amount=12&name=a+b" {
+		t.Fatalf("Marshal = %s", data)
+	}
+
+	var out formPayload
+	if err := FormCodec.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "a b" || out.Amount != 12 || out.Skip != "" {
+		t.Fatalf("Unmarshal = %+v", out)
+	}
+}
+
+func TestCodecForContentType(t *testing.T) {
+	cases := map[string]Codec{
+		"application/json; charset=utf-8":   JSONCodec,
+		"application/problem+json":          JSONCodec,
+		"text/xml":                          XMLCodec,
+		"application/x-www-form-urlencoded": FormCodec,
+		"application/unknown":               nil,
+	}
+	for ct, want := range cases {
+		if got := CodecForContentType(ct); got != want {
+			t.Errorf("CodecForContentType(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+type xmlQuote struct {
+	XMLName xml.Name `xml:"Quote"`
+	Symbol  string   `xml:"Symbol"`
+	Price   string   `xml:"Price"`
+}
+
+func TestDoCodecResponseNegotiatesXML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		if r.Header.Get(HTTPHeaderContentType) != FormCodec.ContentType() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(HTTPHeaderContentType, "text/xml")
+		if values.Get("name") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("<Error><Code>400</Code><Message>name required</Message></Error>"))
+			return
+		}
+		w.Write([]byte("<Quote><Symbol>" + values.Get("name") + "</Symbol><Price>1.5</Price></Quote>"))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var quote xmlQuote
+	resp, err := client.Conn.DoCodecResponse("POST", "/quote", nil, nil, FormCodec, &formPayload{Name: "USD"}, &quote)
+	if err != nil || resp.BodyDecodeError != nil {
+		t.Fatalf("DoCodecResponse: %v %v", err, resp.BodyDecodeError)
+	}
+	if resp.Codec != XMLCodec || quote.Symbol != "USD" || quote.Price != "1.5" {
+		t.Fatalf("unexpected quote %+v", quote)
+	}
+
+	_, err = client.Conn.DoCodecResponse("POST", "/quote", nil, nil, FormCodec, &formPayload{}, &quote)
+	srvErr, ok := err.(*ServiceError)
+	if !ok || srvErr.Code != 400 || srvErr.Message != "name required" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package x_http_client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrDecompressedSizeExceeded is returned while reading a response body that decodes to more than Config.MaxDecompressedSize
+var ErrDecompressedSizeExceeded = errors.New("decompressed response body exceeds the size limit")
+
+// Decompressor returns a reader decoding r
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+var (
+	decompressorsLock sync.RWMutex
+	decompressors     = map[string]Decompressor{
+		"gzip":    newGzipReader,
+		"deflate": newDeflateReader,
+	}
+)
+
+// RegisterDecompressor registers the decoder of a Content-Encoding, such as br or zstd
+func RegisterDecompressor(encoding string, decompressor Decompressor) {
+	decompressorsLock.Lock()
+	defer decompressorsLock.Unlock()
+	decompressors[strings.ToLower(encoding)] = decompressor
+}
+
+func decompressorFor(encoding string) Decompressor {
+	decompressorsLock.RLock()
+	defer decompressorsLock.RUnlock()
+	return decompressors[strings.ToLower(strings.TrimSpace(encoding))]
+}
+
+func newGzipReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// newDeflateReader decodes zlib streams as HTTP defines deflate, and raw deflate streams that some servers send
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	// zlib header: compression method 8 and a check sum divisible by 31
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// acceptEncoding returns the Accept-Encoding of Config.AcceptEncodings that have a decoder
+func (conn Conn) acceptEncoding() string {
+	var encodings []string
+	for _, encoding := range conn.config.AcceptEncodings {
+		if decompressorFor(encoding) != nil {
+			encodings = append(encodings, encoding)
+		}
+	}
+	return strings.Join(encodings, ", ")
+}
+
+// decompressResponse decodes the body of resp, the caller checks that the client asked for the encoding
+func (conn Conn) decompressResponse(resp *http.Response) {
+	encoding := resp.Header.Get(HTTPHeaderContentEncoding)
+	if encoding == "" || resp.Body == nil {
+		return
+	}
+	decompressor := decompressorFor(encoding)
+	if decompressor == nil {
+		return
+	}
+
+	resp.Body = &decodingReadCloser{body: resp.Body, decompressor: decompressor, remaining: conn.config.MaxDecompressedSize}
+	resp.Header.Del(HTTPHeaderContentEncoding)
+	resp.Header.Del(HTTPHeaderContentLength)
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decodingReadCloser decodes the body on the first read, so empty bodies and streams don't block or fail early
+type decodingReadCloser struct {
+	body         io.ReadCloser
+	decompressor Decompressor
+	decoder      io.ReadCloser
+	remaining    int64 // decoded bytes left before ErrDecompressedSizeExceeded, 0 for no limit
+	limited      bool
+	err          error
+}
+
+func (rc *decodingReadCloser) Read(p []byte) (int, error) {
+	if rc.err != nil {
+		return 0, rc.err
+	}
+	if rc.decoder == nil {
+		rc.decoder, rc.err = rc.decompressor(rc.body)
+		if rc.err != nil {
+			return 0, rc.err
+		}
+		rc.limited = rc.remaining > 0
+	}
+
+	if rc.limited && int64(len(p)) > rc.remaining+1 {
+		p = p[:rc.remaining+1]
+	}
+	n, err := rc.decoder.Read(p)
+	if rc.limited {
+		if int64(n) > rc.remaining {
+			n, err = int(rc.remaining), ErrDecompressedSizeExceeded
+		}
+		rc.remaining -= int64(n)
+	}
+	if err != nil {
+		rc.err = err
+	}
+	return n, err
+}
+
+func (rc *decodingReadCloser) Close() error {
+	if rc.decoder != nil {
+		rc.decoder.Close()
+	}
+	return rc.body.Close()
+}
+
+// compressBody gzips a request body of known length reaching Config.RequestCompressionThreshold,
+// returning the body to send and its uncompressed length
+func (conn Conn) compressBody(req *http.Request, headers map[string]string, data io.Reader) (io.Reader, int64, error) {
+	length, err := GetReaderLen(data)
+	threshold := conn.config.RequestCompressionThreshold
+	if data == nil || err != nil || threshold <= 0 || length < threshold || headerValue(headers, HTTPHeaderContentEncoding) != "" {
+		return data, length, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, data); err != nil {
+		return nil, 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set(HTTPHeaderContentEncoding, "gzip")
+	conn.config.WriteLog(Debug, "[Req:%p]gzip body %d -> %d bytes\n", req, length, buf.Len())
+	return bytes.NewReader(buf.Bytes()), length, nil
+}
+
+// progressReader publishes TransferDataEvent as the request body is sent
+type progressReader struct {
+	io.ReadCloser
+	listener     ProgressListener
+	tracker      *readerTracker
+	total        int64
+	contentBytes int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.tracker.completedBytes += int64(n)
+		event := newProgressEvent(TransferDataEvent, r.tracker.completedBytes, r.total, int64(n))
+		event.ContentBytes = r.contentBytes
+		publishProgress(r.listener, event)
+	}
+	return n, err
+}
@@ -0,0 +1,23 @@
+// Package brotli provides the br response decoder.
+// Importing the package registers it, add Encoding to Config.AcceptEncodings to request brotli responses.
+package brotli
+
+import (
+	"io"
+	"io/ioutil"
+
+	xhttp "github.com/872409/ghttpclient"
+	"github.com/andybalholm/brotli"
+)
+
+// Encoding is the Content-Encoding of brotli bodies
+const Encoding = "br"
+
+func init() {
+	xhttp.RegisterDecompressor(Encoding, NewReader)
+}
+
+// NewReader implements xhttp.Decompressor
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(r)), nil
+}
@@ -0,0 +1,36 @@
+package brotli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	xhttp "github.com/872409/ghttpclient"
+	"github.com/andybalholm/brotli"
+)
+
+func TestDecompress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(xhttp.HTTPHeaderAcceptEncoding) != "br, gzip" {
+			t.Errorf("unexpected Accept-Encoding %q", r.Header.Get(xhttp.HTTPHeaderAcceptEncoding))
+		}
+		w.Header().Set(xhttp.HTTPHeaderContentEncoding, Encoding)
+		bw := brotli.NewWriter(w)
+		bw.Write([]byte("fee table"))
+		bw.Close()
+	}))
+	defer server.Close()
+
+	client, err := xhttp.New(server.URL, "id", "secret", xhttp.AcceptEncodings(Encoding, "gzip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Conn.Do("GET", "/fees", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if body := resp.GetBodyText(); body != "fee table" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
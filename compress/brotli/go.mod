@@ -0,0 +1,8 @@
+module github.com/872409/ghttpclient/compress/brotli
+
+go 1.24
+
+require (
+	github.com/872409/ghttpclient v0.1.0
+	github.com/andybalholm/brotli v1.2.6
+)
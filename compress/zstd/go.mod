@@ -0,0 +1,8 @@
+module github.com/872409/ghttpclient/compress/zstd
+
+go 1.24
+
+require (
+	github.com/872409/ghttpclient v0.1.0
+	github.com/klauspost/compress v1.19.2
+)
@@ -0,0 +1,29 @@
+// Package zstd provides the zstd response decoder.
+// Importing the package registers it, add Encoding to Config.AcceptEncodings to request zstd responses.
+package zstd
+
+import (
+	"io"
+
+	xhttp "github.com/872409/ghttpclient"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encoding is the Content-Encoding of zstd bodies
+const Encoding = "zstd"
+
+// maxWindowSize bounds the decoder memory, RFC 8878 asks HTTP decoders to support 8MB windows
+const maxWindowSize = 8 << 20
+
+func init() {
+	xhttp.RegisterDecompressor(Encoding, NewReader)
+}
+
+// NewReader implements xhttp.Decompressor
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxWindowSize))
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
@@ -0,0 +1,33 @@
+package zstd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	xhttp "github.com/872409/ghttpclient"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecompress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(xhttp.HTTPHeaderContentEncoding, Encoding)
+		zw, _ := zstd.NewWriter(w)
+		zw.Write([]byte("fee table"))
+		zw.Close()
+	}))
+	defer server.Close()
+
+	client, err := xhttp.New(server.URL, "id", "secret", xhttp.AcceptEncodings(Encoding))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Conn.Do("GET", "/fees", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if body := resp.GetBodyText(); body != "fee table" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
@@ -0,0 +1,94 @@
+package x_http_client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type progressRecorder struct {
+	events []ProgressEvent
+}
+
+func (r *progressRecorder) ProgressChanged(event *ProgressEvent) {
+	r.events = append(r.events, *event)
+}
+
+func TestResponseDecompression(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HTTPHeaderAcceptEncoding) != "gzip, deflate" {
+			t.Errorf("unexpected Accept-Encoding %q", r.Header.Get(HTTPHeaderAcceptEncoding))
+		}
+		w.Header().Set(HTTPHeaderContentEncoding, "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write(bytes.Repeat([]byte("a"), 4096))
+		zw.Close()
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Conn.Do("GET", "/currencies", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp)
+	resp.Close()
+	if err != nil || len(body) != 4096 || resp.Headers.Get(HTTPHeaderContentEncoding) != "" {
+		t.Fatalf("unexpected body of %d bytes: %v", len(body), err)
+	}
+
+	// size bomb guard
+	client.Config.MaxDecompressedSize = 1024
+	resp, err = client.Conn.Do("GET", "/currencies", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = ioutil.ReadAll(resp)
+	resp.Close()
+	if err != ErrDecompressedSizeExceeded || len(body) != 1024 {
+		t.Fatalf("expected ErrDecompressedSizeExceeded after 1024 bytes, got %d bytes: %v", len(body), err)
+	}
+}
+
+func TestRequestCompression(t *testing.T) {
+	payload := strings.Repeat(`{"amount":0}`, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HTTPHeaderContentEncoding) != "gzip" {
+			t.Errorf("body not compressed")
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if body, _ := ioutil.ReadAll(zr); string(body) != payload {
+			t.Errorf("unexpected body %q", body)
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", RequestCompression(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &progressRecorder{}
+	resp, err := client.Conn.Do("POST", "/account/trade/withdraw/pre", nil, nil, strings.NewReader(payload), recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+
+	last := recorder.events[len(recorder.events)-1]
+	if last.EventType != TransferCompletedEvent || last.ContentBytes != int64(len(payload)) ||
+		last.TotalBytes >= last.ContentBytes || last.ConsumedBytes != last.TotalBytes {
+		t.Fatalf("unexpected progress %+v", last)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -46,6 +48,12 @@ type CredentialsProvider interface {
 	GetCredentials() Credentials
 }
 
+// CredentialsLoader is implemented by CredentialsProviders that can fail, such as ones fetching temporary credentials.
+// A failure is returned as a *SignatureError instead of signing with stale credentials.
+type CredentialsLoader interface {
+	LoadCredentials() (Credentials, error)
+}
+
 type defaultCredentials struct {
 	config *Config
 }
@@ -74,35 +82,93 @@ type Config struct {
 	UserAgent  string
 	Timeout    uint
 
-	Endpoint        string // OSS endpoint
+	Endpoint string // OSS endpoint
+
+	Endpoints                []Endpoint       // Endpoints to spread requests over, Endpoint is used when empty
+	EndpointSelector         EndpointSelector // Selection policy, round-robin by default
+	EndpointFailureThreshold int              // Consecutive failures ejecting an endpoint, 0 never ejects
+	EndpointCooldown         time.Duration    // Time an ejected endpoint stays out of selection
+
+	CircuitBreaker *CircuitBreakerConfig // Circuit breaking per endpoint, disabled when nil
+
+	RateLimits []RateLimit // Client-side QPS and concurrency caps, paused by Retry-After and X-RateLimit-* headers
+
+	Hedging *HedgingConfig // Hedged GET and HEAD requests, disabled when nil
+
+	Cache            CacheStorage // Storage of the HTTP response cache, disabled when nil
+	CacheMaxBodySize int64        // Largest body stored in the cache, larger ones are streamed uncached, 0 for no limit
+
+	CoalesceRequests bool     // Merge concurrent identical GET and HEAD requests into one upstream call
+	CoalesceHeaders  []string // Request headers that must also match to merge requests, such as Accept
+
 	AccessAppID     string // AccessId
 	AccessAppSecret string // AccessKey
 	SecurityToken   string // AccessKey
 
 	AuthVersion AuthVersionType // AccessKey
 
+	ClockSkewThreshold  time.Duration // Measured clock skew that corrects the signed Date, 5s by default, negative never corrects
+	ClockSkewErrorCodes []int         // Error codes of requests rejected for their Date, besides RequestTimeTooSkewed and the like
+
 	HTTPTimeout  HTTPTimeout  // HTTP timeout
 	HTTPMaxConns HTTPMaxConns // Http max connections
+	HTTP2        *HTTP2Config // HTTP/2 and h2c settings, HTTP/1.1 only when nil
 
 	LocalAddr net.Addr
 
+	Resolver          Resolver            // Resolves the endpoint hosts, net.DefaultResolver when nil
+	StaticHosts       map[string][]string // Addresses of hosts dialed without a lookup, like /etc/hosts entries
+	DNSCacheTTL       time.Duration       // Time resolved addresses are reused, 0 disables the cache
+	IPPreference      IPPreference        // Address family dialed first, or the only one dialed
+	DialFallbackDelay time.Duration       // Happy-eyeballs delay before racing the other family, 300ms by default, negative disables the race
+	UnixSocket        string              // Dial this Unix domain socket for every connection, such as a sidecar proxy; unix:// endpoints keep their own socket
+
+	TLS *TLSConfig // CAs, client certificates and pins of TLS connections, Go defaults when nil
+
 	CredentialsProvider CredentialsProvider
 
 	AdditionalHeaders []string
-	RedirectEnabled   bool
+	IdempotencyKeys   bool            // Add a generated Idempotency-Key to unsafe requests without one, which makes them safe to retry
+	RedirectEnabled   bool            // Follow redirects with the default RedirectPolicy
+	Redirect          *RedirectPolicy // Follow redirects re-signing every hop, enabled when not nil
+
+	Codec Codec // Default codec of DoCodecResponse
+
+	ErrorDecoder   ServiceErrorDecoder // Decodes error bodies of every endpoint, negotiated from the Content-Type when nil
+	EnvelopeErrors bool                // Treat 2xx JSON responses up to 1MB whose envelope code is not a success code as *ServiceError
+
+	EnvelopeSuccessCodes []int // Envelope codes meaning success, only 0 when empty
 
-	MD5Threshold        int64
-	IsEnableMD5         bool
+	RequestOptions []RequestOption // Applied to every request before its own options, WithBody and WithProgress are not allowed
 
-	IsUseProxy          bool                // Flag of using proxy.
-	ProxyHost           string              // Flag of using proxy host.
-	IsAuthProxy         bool                // Flag of needing authentication.
-	ProxyUser           string              // Proxy user
-	ProxyPassword       string              // Proxy password
+	StreamRetryInterval time.Duration // Delay before reconnecting an event stream, until the server sends retry
+	StreamMaxReconnects int           // Consecutive failed reconnects before an event stream gives up, 0 for unlimited
 
+	WebSocketPingInterval time.Duration // Interval of keep-alive pings, no pings when not positive
+	WebSocketPongWait     time.Duration // Time allowed to read the next pong or message, no read deadline when not positive
+	WebSocketWriteTimeout time.Duration // Time allowed to write a message
 
-	LogLevel            int                 // Log level
-	Logger              *log.Logger         // For write log
+	WebSocketMaxMessageSize int64 // Largest message read, larger ones close the connection with 1009; raise it for bigger messages, no limit when not positive
+
+	AcceptEncodings             []string // Response encodings requested and decoded: gzip, deflate and those of RegisterDecompressor
+	MaxDecompressedSize         int64    // Decoded response bodies larger than this fail with ErrDecompressedSizeExceeded, 64MB by default, raised with the MaxDecompressedSize option, 0 for no limit
+	RequestCompressionThreshold int64    // Request bodies of known length from this size are sent gzip compressed, 0 disables
+
+	MD5Threshold int64
+	IsEnableMD5  bool
+
+	IsUseProxy    bool   // Flag of using proxy.
+	ProxyHost     string // Flag of using proxy host, http://, https:// or socks5:// URL
+	IsAuthProxy   bool   // Flag of needing authentication.
+	ProxyUser     string // Proxy user
+	ProxyPassword string // Proxy password
+
+	ProxyFromEnvironment     bool                                      // Use HTTP_PROXY, HTTPS_PROXY and NO_PROXY when no proxy is set
+	ProxyFunc                func(req *http.Request) (*url.URL, error) // Proxy of each request, nil URL for direct, takes precedence over ProxyHost
+	ProxyCredentialsProvider ProxyCredentialsProvider                  // Credentials of proxies whose URL has none, ProxyUser and ProxyPassword by default
+
+	LogLevel int         // Log level
+	Logger   *log.Logger // For write log
 }
 
 // WriteLog output log function
@@ -122,6 +188,14 @@ func (config *Config) GetCredentials() Credentials {
 	return config.CredentialsProvider.GetCredentials()
 }
 
+// LoadCredentials gets the credentials, reporting the error of a CredentialsLoader
+func (config *Config) LoadCredentials() (Credentials, error) {
+	if loader, ok := config.CredentialsProvider.(CredentialsLoader); ok {
+		return loader.LoadCredentials()
+	}
+	return config.CredentialsProvider.GetCredentials(), nil
+}
+
 func getDefaultConfig() *Config {
 	config := &Config{}
 
@@ -133,6 +207,11 @@ func getDefaultConfig() *Config {
 	config.Timeout = 60 // Seconds
 	config.SecurityToken = ""
 
+	config.ClockSkewThreshold = time.Second * 5 // 5s
+
+	config.EndpointFailureThreshold = 3
+	config.EndpointCooldown = time.Second * 30 // 30s
+
 	config.HTTPTimeout.ConnectTimeout = time.Second * 30   // 30s
 	config.HTTPTimeout.ReadWriteTimeout = time.Second * 60 // 60s
 	config.HTTPTimeout.HeaderTimeout = time.Second * 60    // 60s
@@ -146,6 +225,24 @@ func getDefaultConfig() *Config {
 	config.IsAuthProxy = false
 	config.ProxyUser = ""
 	config.ProxyPassword = ""
+	config.ProxyFromEnvironment = false
+	config.ProxyCredentialsProvider = &defaultProxyCredentialsProvider{config: config}
+
+	config.Codec = JSONCodec
+
+	config.StreamRetryInterval = time.Second * 3 // 3s
+	config.StreamMaxReconnects = 0
+
+	config.WebSocketPingInterval = time.Second * 30 // 30s
+	config.WebSocketPongWait = time.Second * 60     // 60s
+	config.WebSocketWriteTimeout = time.Second * 10 // 10s
+	config.WebSocketMaxMessageSize = 1024 * 1024    // 1MB
+
+	config.CacheMaxBodySize = 1024 * 1024 // 1MB
+
+	config.AcceptEncodings = []string{"gzip", "deflate"}
+	config.MaxDecompressedSize = 64 * 1024 * 1024 // 64MB
+	config.RequestCompressionThreshold = 0
 
 	config.MD5Threshold = 16 * 1024 * 1024 // 16MB
 	config.IsEnableMD5 = false
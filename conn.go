@@ -2,8 +2,8 @@ package x_http_client
 
 import (
 	"bytes"
-	"encoding/base64"
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -13,79 +13,282 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type Conn struct {
-	config *Config
-	url    *urlMaker
-	client *http.Client
+	config       *Config
+	endpoints    *endpointPool
+	breakers     *circuitBreakers
+	limiters     rateLimiters
+	hedger       *hedger
+	cache        *responseCache
+	coalescer    *coalescer
+	resolver     *hostResolver
+	client       *http.Client
+	streamClient *http.Client // client without read timeouts for long-lived streams
 }
 
-func (conn *Conn) init(config *Config, urlMaker *urlMaker, client *http.Client) error {
+func (conn *Conn) init(config *Config, endpoints *endpointPool, client *http.Client) error {
+	streamClient := client
+	conn.resolver = newHostResolver(config, endpoints)
 	if client == nil {
 		// New transport
 		transport := newTransport(conn, config)
 
-		// Proxy
-		if conn.config.IsUseProxy {
-			proxyURL, err := url.Parse(config.ProxyHost)
+		// TLS
+		if config.TLS != nil {
+			tlsConfig, err := newTLSConfig(config.TLS)
 			if err != nil {
 				return err
 			}
-			if config.IsAuthProxy {
-				if config.ProxyPassword != "" {
-					proxyURL.User = url.UserPassword(config.ProxyUser, config.ProxyPassword)
-				} else {
-					proxyURL.User = url.User(config.ProxyUser)
-				}
-			}
-			transport.Proxy = http.ProxyURL(proxyURL)
+			transport.TLSClientConfig = tlsConfig
+		}
+
+		// Proxy
+		proxy, err := newProxyFunc(config)
+		if err != nil {
+			return err
 		}
+		transport.Proxy = proxy
 		client = &http.Client{Transport: transport}
-		if !config.RedirectEnabled {
-			client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			}
+
+		streamTransport := transport.Clone()
+		streamTransport.DialContext = newDialContext(config, conn.resolver, 0, 0)
+		if streamTransport.DialTLSContext != nil {
+			streamTransport.DialTLSContext = newDialTLSContext(config, conn.resolver, func() *tls.Config { return streamTransport.TLSClientConfig }, 0, 0)
 		}
+		streamClient = &http.Client{Transport: streamTransport}
+
+		checkRedirect := newCheckRedirect(conn, redirectPolicy(config))
+		client.CheckRedirect = checkRedirect
+		streamClient.CheckRedirect = checkRedirect
 	}
 
 	conn.config = config
-	conn.url = urlMaker
+	conn.endpoints = endpoints
+	conn.breakers = newCircuitBreakers(config.CircuitBreaker)
+	conn.limiters = newRateLimiters(config.RateLimits)
+	conn.hedger = newHedger(config.Hedging)
+	conn.cache = newResponseCache(config.Cache)
+	conn.coalescer = newCoalescer(config.CoalesceRequests, config.CoalesceHeaders)
 	conn.client = client
+	conn.streamClient = streamClient
 
 	return nil
 }
 
 // Do sends request and returns the response
 func (conn Conn) Do(method, path string, params map[string]interface{}, headers map[string]string, data io.Reader, listener ProgressListener) (*Response, error) {
-	urlParams := conn.getURLParams(params)
-	uri := conn.url.getURL(path, urlParams)
-	return conn.doRequest(method, uri, headers, data, listener)
+	return conn.DoWithContext(context.Background(), method, path, params, headers, data, listener)
 }
 
-// Do sends request and returns the response
-func (conn Conn) DoJSONResponse(method, path string, params map[string]interface{}, headers map[string]string, data interface{}, responseJSON interface{}) (*JSONResponse, error) {
-	urlParams := conn.getURLParams(params)
-	uri := conn.url.getURL(path, urlParams)
-	resp, respErr := conn.doRequest(method, uri, headers, interface2JSONReader(data), nil)
-	jsonResponse := &JSONResponse{Response: resp}
+// DoWithContext sends request bound to ctx and returns the response
+func (conn Conn) DoWithContext(ctx context.Context, method, path string, params map[string]interface{}, headers map[string]string, data io.Reader, listener ProgressListener) (*Response, error) {
+	return conn.Send(ctx, method, path, WithQuery(params), WithHeaders(headers), WithBody(data), WithProgress(listener))
+}
 
-	jsonText, jsonErr := conn.jsonUnmarshal(resp.Body, responseJSON)
-	jsonResponse.BodyJSONError = jsonErr
-	jsonResponse.bodyText = jsonText
+// request is one logical request going through the send pipeline
+type request struct {
+	ctx       context.Context
+	client    *http.Client
+	method    string
+	path      string
+	urlParams string
+	headers   map[string]string
+	data      io.Reader
+	listener  ProgressListener
+	retry     *RetryPolicy
+	attempt   int // attempts sent so far, over failovers and retries
+
+	idempotencyKey string          // Idempotency-Key of the logical request, shared by its retries
+	hedges         *hedgeEndpoints // endpoints used by the concurrent attempts of a hedged request
+}
 
-	return jsonResponse, respErr
+// send sends the request through the response cache when enabled
+func (conn Conn) send(req *request) (*Response, error) {
+	if conn.cache != nil {
+		return conn.sendCached(req)
+	}
+	return conn.sendUncached(req)
 }
 
-func interface2JSONReader(v interface{}) *strings.Reader {
-	bs, e := json.Marshal(v)
-	if e != nil {
-		return nil
+// sendUncached sends the request, sharing the call of identical concurrent requests when enabled
+func (conn Conn) sendUncached(req *request) (*Response, error) {
+	if conn.coalescer.applies(req) {
+		return conn.sendCoalesced(req)
+	}
+	return conn.sendAttempt(req)
+}
+
+// sendAttempt sends the request, retrying it when it has a RetryPolicy
+func (conn Conn) sendAttempt(req *request) (*Response, error) {
+	if req.retry != nil {
+		return conn.sendRetried(req)
+	}
+	return conn.sendOnce(req)
+}
+
+// sendOnce sends the request, hedging it when enabled
+func (conn Conn) sendOnce(req *request) (*Response, error) {
+	if conn.hedger.applies(req) {
+		return conn.sendHedged(req)
+	}
+	return conn.sendLimited(req)
+}
+
+// sendLimited waits for the rate limits of the path and sends the request
+func (conn Conn) sendLimited(req *request) (*Response, error) {
+	release, err := conn.limiters.acquire(req.ctx, req.path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := conn.sendEndpoints(req)
+	// error and buffered bodies are often never closed, only a streamed body holds the slots until Close
+	if err != nil || resp == nil || resp.Body == nil || isBuffered(resp.Body) {
+		release()
+	} else {
+		resp.Body = &releaseReadCloser{ReadCloser: resp.Body, release: release}
+	}
+	return resp, err
+}
+
+// sendEndpoints sends the request to a selected endpoint, failing over to the other endpoints when it can't reach one
+func (conn Conn) sendEndpoints(req *request) (*Response, error) {
+	ctx := req.ctx
+	rewind := bodyRewinder(req.data)
+	maxAttempts := len(conn.endpoints.endpoints)
+	if retries := int(conn.config.RetryTimes); retries+1 < maxAttempts {
+		maxAttempts = retries + 1
+	}
+
+	var tried []*EndpointState
+	var retry *EndpointState // sent again once its clock was corrected
+	skewRetried := false
+	attempts := 0
+	for {
+		ep := retry
+		if ep == nil {
+			ep = conn.endpoints.pick(append(tried, req.hedges.inUse()...))
+			tried = append(tried, ep)
+			req.hedges.add(ep)
+		}
+		retry = nil
+
+		uri, err := ep.url.getURL(req.path, req.urlParams)
+		if err != nil {
+			return nil, err
+		}
+
+		breaker := conn.breakers.get(ep.URL, req.path)
+		if err := breaker.allow(); err != nil {
+			// nothing was sent, another endpoint may take the request
+			if len(tried) >= len(conn.endpoints.endpoints) {
+				return nil, err
+			}
+			continue
+		}
+		attempts++
+
+		offset := ep.ClockOffset()
+		atomic.AddInt64(&ep.inFlight, 1)
+		resp, err := conn.doRequest(ctx, req.client, req.method, uri, req.headers, req.data, req.listener, ep)
+		atomic.AddInt64(&ep.inFlight, -1)
+		if ctx.Err() != nil {
+			// the caller gave up, the attempt says nothing about the endpoint
+			breaker.release()
+		} else {
+			failed := isEndpointFailure(resp, err)
+			conn.endpoints.report(ep, failed)
+			breaker.report(failed)
+		}
+		conn.limiters.adapt(req.path, resp)
+
+		if resp != nil {
+			resp.Endpoint = ep.URL
+			resp.IdempotencyKey = req.idempotencyKey
+		}
+		req.attempt++
+		err = annotateError(err, req.method, uri.String(), ep.URL, req.attempt)
+
+		if !skewRetried && ctx.Err() == nil && conn.isClockSkewError(err) && conn.correctClock(ep, offset) && rewind() == nil {
+			skewRetried = true
+			retry = ep
+			if resp != nil {
+				resp.Close()
+			}
+			continue
+		}
+
+		if resp != nil || err == nil || ctx.Err() != nil || attempts >= maxAttempts ||
+			len(tried) >= len(conn.endpoints.endpoints) || !canFailover(req.method, req.idempotencyKey, err) || rewind() != nil {
+			return resp, err
+		}
+		if req.idempotencyKey != "" {
+			conn.config.WriteLog(Warn, "[Endpoint:%s]%s %s failed:%s, failover with Idempotency-Key %s\n", ep.URL, req.method, req.path, err.Error(), req.idempotencyKey)
+		} else {
+			conn.config.WriteLog(Warn, "[Endpoint:%s]%s %s failed:%s, failover\n", ep.URL, req.method, req.path, err.Error())
+		}
+	}
+}
+
+// DoJSONResponse sends data encoded as JSON and decodes the JSON response body into responseJSON
+func (conn Conn) DoJSONResponse(method, path string, params map[string]interface{}, headers map[string]string, data interface{}, responseJSON interface{}) (*JSONResponse, error) {
+	resp, err := conn.DoCodecResponse(method, path, params, headers, JSONCodec, data, responseJSON)
+	if resp == nil {
+		return nil, err
+	}
+	return &JSONResponse{Response: resp.Response, BodyJSONError: resp.BodyDecodeError}, err
+}
+
+// DoCodecResponse sends data encoded by codec and decodes the response body into responseValue.
+// A nil codec uses Config.Codec. The response codec is negotiated from the response Content-Type
+// and falls back to the request codec.
+func (conn Conn) DoCodecResponse(method, path string, params map[string]interface{}, headers map[string]string, codec Codec, data interface{}, responseValue interface{}) (*CodecResponse, error) {
+	if codec == nil {
+		codec = conn.config.Codec
+	}
+
+	body, err := encodeBody(codec, data)
+	if err != nil {
+		return nil, err
+	}
+
+	reqHeaders := make(map[string]string, len(headers)+2)
+	if body != nil {
+		reqHeaders[HTTPHeaderContentType] = codec.ContentType()
 	}
-	// fmt.Printf("interface2JSONReader:%v \n", string(bs))
-	return strings.NewReader(string(bs))
+	reqHeaders[HTTPHeaderAccept] = acceptHeader(codec)
+	for k, v := range headers {
+		reqHeaders[k] = v
+	}
+
+	resp, respErr := conn.Do(method, path, params, reqHeaders, body, nil)
+	if resp == nil {
+		return nil, respErr
+	}
+
+	// prefer the caller's codec instance when the server answered in its media type
+	respCodec := CodecForContentType(resp.Headers.Get(HTTPHeaderContentType))
+	if respCodec == nil || respCodec.ContentType() == codec.ContentType() {
+		respCodec = codec
+	}
+	codecResponse := &CodecResponse{Response: resp, Codec: respCodec}
+	if err := conn.unmarshalBody(resp, respCodec, responseValue); err != nil {
+		codecResponse.BodyDecodeError = &DecodeError{
+			Method:      strings.ToUpper(method),
+			StatusCode:  resp.StatusCode,
+			RequestID:   resp.RequestID,
+			ContentType: resp.Headers.Get(HTTPHeaderContentType),
+			RawMessage:  resp.bodyText,
+			Err:         err,
+		}
+	}
+
+	return codecResponse, respErr
 }
+
 func (conn Conn) getURLParams(p interface{}) string {
 	params, ok := p.(map[string]interface{})
 	if !ok {
@@ -114,16 +317,34 @@ func (conn Conn) getURLParams(p interface{}) string {
 	return buf.String()
 }
 
-func (conn Conn) doRequest(method string, uri *url.URL, headers map[string]string, data io.Reader, listener ProgressListener) (*Response, error) {
+func (conn Conn) doRequest(ctx context.Context, client *http.Client, method string, uri *url.URL, headers map[string]string, data io.Reader, listener ProgressListener, ep *EndpointState) (*Response, error) {
 	method = strings.ToUpper(method)
 	req := &http.Request{
-		Method:     method,
-		URL:        uri,
-		Proto:      "HTTP/1.1",
-		ProtoMajor: 1,
-		ProtoMinor: 1,
-		Header:     make(http.Header),
-		Host:       uri.Host,
+		Method: method,
+		URL:    uri,
+		Header: make(http.Header),
+		Host:   uri.Host,
+	}
+	if isUnixHost(uri.Host) {
+		// the synthetic host of a unix:// endpoint means nothing to the server
+		req.Host = "localhost"
+	}
+
+	// with HTTP/2 the read timeout applies to the response body, streams have no read timeout
+	var readTimeout *bodyReadTimeout
+	if conn.config.HTTP2 != nil && client == conn.client && conn.config.HTTPTimeout.ReadWriteTimeout > 0 {
+		ctx, readTimeout = newBodyReadTimeout(ctx, conn.config.HTTPTimeout.ReadWriteTimeout)
+	}
+	var redirects *redirectChain
+	if redirectPolicy(conn.config) != nil {
+		ctx, redirects = withRedirectChain(ctx, ep.ClockOffset())
+	}
+	req = req.WithContext(ctx)
+
+	data, contentBytes, err := conn.compressBody(req, headers, data)
+	if err != nil {
+		readTimeout.release()
+		return nil, err
 	}
 
 	tracker := &readerTracker{completedBytes: 0}
@@ -134,48 +355,64 @@ func (conn Conn) doRequest(method string, uri *url.URL, headers map[string]strin
 			os.Remove(fd.Name())
 		}()
 	}
-
-	if conn.config.IsAuthProxy {
-		auth := conn.config.ProxyUser + ":" + conn.config.ProxyPassword
-		basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
-		req.Header.Set("Proxy-Authorization", basic)
+	if req.Body != nil && listener != nil {
+		req.Body = &progressReader{ReadCloser: req.Body, listener: listener, tracker: tracker, total: req.ContentLength, contentBytes: contentBytes}
 	}
 
-	date := time.Now().UTC().Format(http.TimeFormat)
+	date := ep.now().UTC().Format(http.TimeFormat)
 	req.Header.Set(HTTPHeaderDate, date)
 	req.Header.Set(HTTPHeaderHost, req.Host)
 	req.Header.Set(HTTPHeaderUserAgent, conn.config.UserAgent)
 
-	akIf := conn.config.GetCredentials()
+	akIf, err := conn.config.LoadCredentials()
+	if err != nil {
+		readTimeout.release()
+		return nil, &SignatureError{Method: method, URL: uri.String(), Err: err}
+	}
 	if akIf.GetSecurityToken() != "" {
 		req.Header.Set(HTTPHeaderSecurityToken, akIf.GetSecurityToken())
 	}
 
+	// decode responses only when the client chose the encodings
+	decompress := false
+	if acceptEncoding := conn.acceptEncoding(); acceptEncoding != "" && headerValue(headers, HTTPHeaderAcceptEncoding) == "" {
+		req.Header.Set(HTTPHeaderAcceptEncoding, acceptEncoding)
+		decompress = true
+	}
+
 	if headers != nil {
 		for k, v := range headers {
 			req.Header.Set(k, v)
 		}
 	}
 
-	conn.signHeader(req)
+	conn.signHeader(req, akIf)
 
 	// Transfer started
 	event := newProgressEvent(TransferStartedEvent, 0, req.ContentLength, 0)
+	event.ContentBytes = contentBytes
 	publishProgress(listener, event)
 
 	if conn.config.LogLevel >= Debug {
 		conn.LoggerHTTPReq(req)
 	}
 
-	resp, err := conn.client.Do(req)
+	resp, err := client.Do(req)
 
 	if err != nil {
 		// Transfer failed
 		event = newProgressEvent(TransferFailedEvent, tracker.completedBytes, req.ContentLength, 0)
+		event.ContentBytes = contentBytes
 		publishProgress(listener, event)
 		conn.config.WriteLog(Debug, "[Resp:%p]http error:%s\n", req, err.Error())
+		readTimeout.release()
 		return nil, err
 	}
+	resp.Body = readTimeout.wrap(resp.Body)
+	if len(redirects.redirects()) == 0 {
+		// after a redirect the Date comes from another host
+		ep.measureSkew(resp.Header, time.Now(), conn.config.ClockSkewThreshold)
+	}
 
 	if conn.config.LogLevel >= Debug {
 		// print out http resp
@@ -184,13 +421,24 @@ func (conn Conn) doRequest(method string, uri *url.URL, headers map[string]strin
 
 	// Transfer completed
 	event = newProgressEvent(TransferCompletedEvent, tracker.completedBytes, req.ContentLength, 0)
+	event.ContentBytes = contentBytes
 	publishProgress(listener, event)
 
-	return conn.handleResponse(resp)
+	if decompress {
+		conn.decompressResponse(resp)
+	}
+	response, err := conn.handleResponse(resp, conn.errorDecoder(ep))
+	if response != nil {
+		response.Redirects = redirects.redirects()
+	}
+	if redirectErr, ok := err.(*RedirectError); ok {
+		redirectErr.Err = redirects.stopped()
+	}
+	return response, err
 }
 
-// handleResponse handles response
-func (conn Conn) handleResponse(resp *http.Response) (*Response, error) {
+// handleResponse handles response, error bodies are decoded by errDecoder or negotiated from the Content-Type when nil
+func (conn Conn) handleResponse(resp *http.Response, errDecoder ServiceErrorDecoder) (*Response, error) {
 
 	statusCode := resp.StatusCode
 	requestID := resp.Header.Get(HTTPHeaderRequestID)
@@ -205,16 +453,17 @@ func (conn Conn) handleResponse(resp *http.Response) (*Response, error) {
 		}
 
 		if len(respBody) == 0 {
-			err = ServiceError{
+			err = &ServiceError{
 				StatusCode: statusCode,
 				RequestID:  requestID,
 				TrackID:    trackID,
 			}
 		} else {
 			// Response contains storage service error object, unmarshal
-			srvErr, errIn := serviceErrFromJSON(respBody, resp.StatusCode, requestID)
+			contentType := resp.Header.Get(HTTPHeaderContentType)
+			srvErr, errIn := serviceErrFromBody(respBody, contentType, resp.StatusCode, requestID, errDecoder)
 			if errIn != nil { // error unmarshaling the error response
-				err = fmt.Errorf("service returned invalid response body, status = %s, RequestId = %s", resp.Status, resp.Header.Get(HTTPHeaderRequestID))
+				err = &DecodeError{StatusCode: statusCode, RequestID: requestID, ContentType: contentType, RawMessage: string(respBody), Err: errIn}
 			} else {
 				err = srvErr
 			}
@@ -225,21 +474,61 @@ func (conn Conn) handleResponse(resp *http.Response) (*Response, error) {
 			TrackID:    trackID,
 			StatusCode: resp.StatusCode,
 			Headers:    resp.Header,
-			Body:       ioutil.NopCloser(bytes.NewReader(respBody)), // restore the body
+			Body:       newBufferedBody(respBody), // restore the body
 		}, err
-	} else if statusCode >= 300 && statusCode <= 307 {
+	} else if statusCode >= 300 && statusCode < 400 {
 		// OSS use 3xx, but response has no body
-		err := fmt.Errorf("service returned %d,%s", resp.StatusCode, resp.Status)
+		respBody, err := readResponseBody(resp)
+		if err != nil {
+			return nil, err
+		}
+		err = &RedirectError{StatusCode: statusCode, Location: resp.Header.Get(HTTPHeaderLocation), RequestID: requestID, RawMessage: string(respBody)}
 		return &Response{
 			RequestID:  requestID,
 			TrackID:    trackID,
 			StatusCode: resp.StatusCode,
 			Headers:    resp.Header,
-			Body:       resp.Body,
+			Body:       newBufferedBody(respBody), // restore the body
 		}, err
 	}
 
-	// 2xx, successful
+	// 2xx, successful unless the envelope carries an error code
+	if conn.config.EnvelopeErrors && statusCode != http.StatusNoContent && (resp.Request == nil || resp.Request.Method != string(HTTPHead)) &&
+		isJSONType(resp.Header.Get(HTTPHeaderContentType)) && resp.ContentLength <= envelopeMaxBody {
+		respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, envelopeMaxBody+1))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if len(respBody) > envelopeMaxBody {
+			// too large for an error envelope, stream it unchecked
+			return &Response{
+				RequestID:  requestID,
+				TrackID:    trackID,
+				StatusCode: resp.StatusCode,
+				Headers:    resp.Header,
+				Body: struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(respBody), resp.Body), resp.Body},
+			}, nil
+		}
+		resp.Body.Close()
+		response := &Response{
+			RequestID:  requestID,
+			TrackID:    trackID,
+			StatusCode: resp.StatusCode,
+			Headers:    resp.Header,
+			Body:       newBufferedBody(respBody), // restore the body
+		}
+		if srvErr := conn.envelopeError(respBody, statusCode, requestID); srvErr != nil {
+			if srvErr.TrackID == "" {
+				srvErr.TrackID = trackID
+			}
+			return response, srvErr
+		}
+		return response, nil
+	}
 	return &Response{
 		RequestID:  requestID,
 		TrackID:    trackID,
@@ -315,9 +604,13 @@ func (conn Conn) handleBody(req *http.Request, body io.Reader, listener Progress
 
 	// HTTP body
 	rc, ok := reader.(io.ReadCloser)
-	if !ok && reader != nil {
+	if (!ok || file != nil) && reader != nil {
+		// the temp file is closed by doRequest once redirects can't send it again
 		rc = ioutil.NopCloser(reader)
 	}
+	if _, closer := reader.(io.Closer); !closer || file != nil {
+		req.GetBody = getBody(reader)
+	}
 
 	// if conn.isUploadLimitReq(req) {
 	// 	limitReader := &LimitSpeedReader{
@@ -332,6 +625,25 @@ func (conn Conn) handleBody(req *http.Request, body io.Reader, listener Progress
 	return file
 }
 
+// bufferedBody is a response body already read into memory, closing it releases nothing
+type bufferedBody struct {
+	*bytes.Reader
+}
+
+func newBufferedBody(body []byte) io.ReadCloser {
+	return bufferedBody{bytes.NewReader(body)}
+}
+
+func (bufferedBody) Close() error {
+	return nil
+}
+
+// isBuffered reports whether body was read into memory by handleResponse
+func isBuffered(body io.ReadCloser) bool {
+	_, ok := body.(bufferedBody)
+	return ok
+}
+
 func readResponseBody(resp *http.Response) ([]byte, error) {
 	defer resp.Body.Close()
 	out, err := ioutil.ReadAll(resp.Body)
@@ -341,29 +653,53 @@ func readResponseBody(resp *http.Response) ([]byte, error) {
 	return out, err
 }
 
-func serviceErrFromJSON(body []byte, statusCode int, requestID string) (ServiceError, error) {
-	var storageErr ServiceError
+// serviceErrFromBody decodes the error body with decoder, or else the error decoder registered for contentType
+// or the codec negotiated from it, JSON by default
+func serviceErrFromBody(body []byte, contentType string, statusCode int, requestID string, decoder ServiceErrorDecoder) (*ServiceError, error) {
+	storageErr := &ServiceError{StatusCode: statusCode, RawMessage: string(body)}
 
-	if err := json.Unmarshal(body, &storageErr); err != nil {
+	if decoder == nil {
+		decoder = errorDecoderForContentType(contentType)
+	}
+	codec := CodecForContentType(contentType)
+	if codec == nil {
+		codec = JSONCodec
+	}
+	if decoder == nil {
+		decoder, _ = codec.(ServiceErrorDecoder)
+	}
+	if decoder != nil {
+		if err := decoder.DecodeServiceError(body, storageErr); err != nil {
+			return storageErr, err
+		}
+	} else if err := codec.Unmarshal(body, storageErr); err != nil {
 		return storageErr, err
 	}
 
 	storageErr.StatusCode = statusCode
-	storageErr.RequestID = requestID
+	if requestID != "" || storageErr.RequestID == "" {
+		storageErr.RequestID = requestID
+	}
 	storageErr.RawMessage = string(body)
 	return storageErr, nil
 }
 
-func (conn Conn) jsonUnmarshal(body io.Reader, v interface{}) (string, error) {
-	data, err := ioutil.ReadAll(body)
+// unmarshalBody reads the whole response body, keeps it as body text and decodes it with codec
+func (conn Conn) unmarshalBody(resp *Response, codec Codec, v interface{}) error {
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return err
 	}
-	jsonStr := string(data)
-	err = json.Unmarshal(data, v)
-	if conn.config.LogLevel >= Debug {
-		conn.config.WriteLog(Debug, "jsonUnmarshal:%v err:%v \n", jsonStr, err)
+	resp.bodyText = string(data)
+	resp.isBodyTextRead = true
+	if v == nil || len(data) == 0 {
+		return nil
 	}
 
-	return jsonStr, err
+	err = codec.Unmarshal(data, v)
+	if conn.config.LogLevel >= Debug {
+		conn.config.WriteLog(Debug, "unmarshalBody(%s):%v err:%v \n", codec.ContentType(), resp.bodyText, err)
+	}
+	return err
 }
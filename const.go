@@ -33,33 +33,40 @@ const (
 
 	// HTTPDelete HTTP DELETE
 	HTTPDelete HTTPMethod = "DELETE"
+
+	// HTTPPatch HTTP PATCH
+	HTTPPatch HTTPMethod = "PATCH"
 )
 
 // HTTP headers
 const (
 	HTTPHeaderAcceptEncoding string = "Accept-Encoding"
+	HTTPHeaderAccept                = "Accept"
 	HTTPHeaderAuthorization         = "Authorization"
-	// HTTPHeaderCacheControl              = "Cache-Control"
+	HTTPHeaderCacheControl          = "Cache-Control"
 	// HTTPHeaderContentDisposition        = "Content-Disposition"
-	// HTTPHeaderContentEncoding           = "Content-Encoding"
+	HTTPHeaderContentEncoding = "Content-Encoding"
 	HTTPHeaderContentLength   = "Content-Length"
 	HTTPHeaderContentMD5      = "Content-MD5"
 	HTTPHeaderContentType     = "Content-Type"
 	HTTPHeaderContentLanguage = "Content-Language"
 	HTTPHeaderDate            = "Date"
-	// HTTPHeaderEtag                      = "ETag"
-	// HTTPHeaderExpires                   = "Expires"
-	HTTPHeaderHost = "Host"
-	// HTTPHeaderLastModified              = "Last-Modified"
+	HTTPHeaderAge             = "Age"
+	HTTPHeaderEtag            = "ETag"
+	HTTPHeaderExpires         = "Expires"
+	HTTPHeaderHost            = "Host"
+	HTTPHeaderLastModified    = "Last-Modified"
 	// HTTPHeaderRange                     = "Range"
-	// HTTPHeaderLocation                  = "Location"
+	HTTPHeaderLocation = "Location"
 	// HTTPHeaderOrigin                    = "Origin"
 	// HTTPHeaderServer                    = "Server"
-	HTTPHeaderUserAgent = "User-Agent"
-	// HTTPHeaderIfModifiedSince           = "If-Modified-Since"
+	HTTPHeaderUserAgent       = "User-Agent"
+	HTTPHeaderRetryAfter      = "Retry-After"
+	HTTPHeaderVary            = "Vary"
+	HTTPHeaderIfModifiedSince = "If-Modified-Since"
 	// HTTPHeaderIfUnmodifiedSince         = "If-Unmodified-Since"
 	// HTTPHeaderIfMatch                   = "If-Match"
-	// HTTPHeaderIfNoneMatch               = "If-None-Match"
+	HTTPHeaderIfNoneMatch = "If-None-Match"
 	// HTTPHeaderACReqMethod               = "Access-Control-Request-Method"
 	// HTTPHeaderACReqHeaders              = "Access-Control-Request-Headers"
 
@@ -78,8 +85,12 @@ const (
 	// HTTPHeaderOssCopySourceIfUnmodifiedSince = "X-Oss-Copy-Source-If-Unmodified-Since"
 	// HTTPHeaderOssMetadataDirective           = "X-Oss-Metadata-Directive"
 	// HTTPHeaderOssNextAppendPosition          = "X-Oss-Next-Append-Position"
-	HTTPHeaderTrackID   = "X-Track-Id"
-	HTTPHeaderRequestID = "X-Request-Id"
+	HTTPHeaderLastEventID        = "Last-Event-ID"
+	HTTPHeaderTrackID            = "X-Track-Id"
+	HTTPHeaderRequestID          = "X-Request-Id"
+	HTTPHeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HTTPHeaderRateLimitReset     = "X-RateLimit-Reset"
+	HTTPHeaderIdempotencyKey     = "Idempotency-Key"
 	// HTTPHeaderOssCRC64                       = "X-Oss-Hash-Crc64ecma"
 	// HTTPHeaderOssSymlinkTarget               = "X-Oss-Symlink-Target"
 	// HTTPHeaderOssStorageClass                = "X-Oss-Storage-Class"
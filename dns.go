@@ -0,0 +1,246 @@
+package x_http_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Resolver looks up the addresses of a host, *net.Resolver implements it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// IPPreference defines the address family dialed first
+type IPPreference int
+
+const (
+	// IPPreferDefault tries the family of the first resolved address first, like net.Dialer
+	IPPreferDefault IPPreference = iota
+	// IPPreferIPv4 tries IPv4 addresses first
+	IPPreferIPv4
+	// IPPreferIPv6 tries IPv6 addresses first
+	IPPreferIPv6
+	// IPv4Only dials IPv4 addresses only
+	IPv4Only
+	// IPv6Only dials IPv6 addresses only
+	IPv6Only
+)
+
+// defaultFallbackDelay is the happy-eyeballs delay of net.Dialer
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// hostResolver resolves the hosts dialed by the transport: static hosts first, then the cache and the resolver
+type hostResolver struct {
+	config  *Config
+	sockets map[string]string // synthetic hosts of unix:// endpoints to their socket
+
+	lock  sync.Mutex
+	cache map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+func newHostResolver(config *Config, endpoints *endpointPool) *hostResolver {
+	r := &hostResolver{config: config, sockets: make(map[string]string), cache: make(map[string]dnsCacheEntry)}
+	if endpoints != nil {
+		for _, ep := range endpoints.endpoints {
+			if ep.url.Type == urlTypeUnix {
+				r.sockets[ep.url.NetLoc] = ep.url.Socket
+			}
+		}
+	}
+	return r
+}
+
+// lookup returns the addresses of host
+func (r *hostResolver) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	if addrs, ok := r.config.StaticHosts[host]; ok {
+		var ips []net.IP
+		for _, addr := range addrs {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid static address %q of host %s", addr, host)
+			}
+			ips = append(ips, ip)
+		}
+		return ips, nil
+	}
+
+	ttl := r.config.DNSCacheTTL
+	if ttl > 0 {
+		r.lock.Lock()
+		entry, ok := r.cache[host]
+		r.lock.Unlock()
+		if ok && time.Now().Before(entry.expires) {
+			return entry.ips, nil
+		}
+	}
+
+	var resolver Resolver = net.DefaultResolver
+	if r.config.Resolver != nil {
+		resolver = r.config.Resolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+
+	if ttl > 0 && len(ips) > 0 {
+		now := time.Now()
+		r.lock.Lock()
+		// drop the expired hosts, the cache only holds the hosts resolved within the TTL
+		for cached, entry := range r.cache {
+			if !now.Before(entry.expires) {
+				delete(r.cache, cached)
+			}
+		}
+		r.cache[host] = dnsCacheEntry{ips: ips, expires: now.Add(ttl)}
+		r.lock.Unlock()
+	}
+	return ips, nil
+}
+
+// partition splits ips into the addresses dialed first and the fallbacks of the other family
+func partition(ips []net.IP, preference IPPreference) (primaries, fallbacks []net.IP) {
+	isV4 := func(ip net.IP) bool { return ip.To4() != nil }
+	var firstV4 bool
+	switch preference {
+	case IPPreferIPv4, IPv4Only:
+		firstV4 = true
+	case IPPreferIPv6, IPv6Only:
+		firstV4 = false
+	default:
+		if len(ips) == 0 {
+			return nil, nil
+		}
+		firstV4 = isV4(ips[0])
+	}
+
+	for _, ip := range ips {
+		if isV4(ip) == firstV4 {
+			primaries = append(primaries, ip)
+		} else if preference != IPv4Only && preference != IPv6Only {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// dial connects to addr, resolving its host with the configured resolution and racing the
+// fallback family after the happy-eyeballs delay
+func (r *hostResolver) dial(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.DialContext(ctx, network, addr)
+	}
+
+	ips, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	primaries, fallbacks := partition(ips, r.config.IPPreference)
+	if len(primaries) == 0 {
+		primaries, fallbacks = fallbacks, nil
+	}
+	if len(primaries) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: fmt.Errorf("no suitable address for host %s", host)}
+	}
+
+	delay := r.config.DialFallbackDelay
+	if delay == 0 {
+		delay = defaultFallbackDelay
+	}
+	if len(fallbacks) == 0 || delay < 0 {
+		return dialSerial(ctx, d, network, append(primaries, fallbacks...), port)
+	}
+	return dialParallel(ctx, d, network, primaries, fallbacks, port, delay)
+}
+
+// dialSerial dials the addresses in order until one connects
+func dialSerial(ctx context.Context, d *net.Dialer, network string, ips []net.IP, port string) (net.Conn, error) {
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.New("no address to dial")
+	}
+	return nil, firstErr
+}
+
+// dialParallel races the fallbacks against the primaries once the primaries failed or delay passed
+func dialParallel(ctx context.Context, d *net.Dialer, network string, primaries, fallbacks []net.IP, port string, delay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	results := make(chan dialResult, 2)
+	race := func(ips []net.IP, primary bool) {
+		conn, err := dialSerial(ctx, d, network, ips, port)
+		results <- dialResult{conn: conn, err: err, primary: primary}
+	}
+
+	go race(primaries, true)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var firstErr error
+	fallbackStarted, pending := false, 1
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				go race(fallbacks, false)
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				// the losing race is canceled, a connection it still made is closed
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return result.conn, nil
+			}
+			if firstErr == nil || result.primary {
+				firstErr = result.err
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				timer.Stop()
+				go race(fallbacks, false)
+			}
+		}
+	}
+	return nil, firstErr
+}
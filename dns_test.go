@@ -0,0 +1,125 @@
+package x_http_client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingResolver struct {
+	lookups int32
+}
+
+func (r *countingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	atomic.AddInt32(&r.lookups, 1)
+	return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+}
+
+func TestStaticHostsAndDNSCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	resolver := &countingResolver{}
+	client, err := New("http://api.internal:"+port, "id", "secret",
+		StaticHosts(map[string][]string{"api.internal": {"127.0.0.1"}}), DNSCache(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Config.Resolver = resolver
+
+	resp, err := client.Conn.Do("GET", "/", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := resp.GetBodyText(); host != "api.internal:"+port {
+		t.Fatalf("unexpected Host %q", host)
+	}
+	resp.Close()
+
+	client, err = New("http://dns.internal:"+port, "id", "secret", DNSCache(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Config.Resolver = resolver
+	client.Conn.client.Transport.(*http.Transport).DisableKeepAlives = true // dial every request
+	for i := 0; i < 3; i++ {
+		resp, err := client.Conn.Do("GET", "/", nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Close()
+	}
+	// the static host was never looked up
+	if resolver.lookups != 1 {
+		t.Fatalf("expected 1 lookup, got %d", resolver.lookups)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "sidecar.sock"))
+	if err != nil {
+		t.Skip(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("sidecar"))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client, err := New("http://gateway.internal", "id", "secret", UnixSocket(listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Conn.Do("GET", "/", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if resp.GetBodyText() != "sidecar" {
+		t.Fatalf("unexpected body %q", resp.GetBodyText())
+	}
+}
+
+func TestPartition(t *testing.T) {
+	v4, v6 := net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")
+	ips := []net.IP{v6, v4}
+
+	if primaries, fallbacks := partition(ips, IPPreferDefault); !primaries[0].Equal(v6) || !fallbacks[0].Equal(v4) {
+		t.Fatalf("default: %v %v", primaries, fallbacks)
+	}
+	if primaries, fallbacks := partition(ips, IPPreferIPv4); !primaries[0].Equal(v4) || !fallbacks[0].Equal(v6) {
+		t.Fatalf("prefer IPv4: %v %v", primaries, fallbacks)
+	}
+	if primaries, fallbacks := partition(ips, IPv4Only); len(primaries) != 1 || len(fallbacks) != 0 {
+		t.Fatalf("IPv4 only: %v %v", primaries, fallbacks)
+	}
+}
+
+func TestDNSCacheEviction(t *testing.T) {
+	config := getDefaultConfig()
+	config.DNSCacheTTL = 20 * time.Millisecond
+	config.Resolver = &countingResolver{}
+	r := newHostResolver(config, nil)
+
+	for _, host := range []string{"a.internal", "b.internal"} {
+		if _, err := r.lookup(context.Background(), host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := r.lookup(context.Background(), "c.internal"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.cache["c.internal"]; len(r.cache) != 1 || !ok {
+		t.Fatalf("expired hosts kept: %v", r.cache)
+	}
+}
@@ -0,0 +1,261 @@
+package x_http_client
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Endpoint is one address of the service
+type Endpoint struct {
+	URL      string // Endpoint such as https://zone-a.example.com
+	Weight   int    // Relative weight for WeightedSelector, 1 if not positive
+	Priority int    // PrioritySelector prefers the lowest priority
+
+	ErrorDecoder ServiceErrorDecoder // Decodes the error bodies of the endpoint, Config.ErrorDecoder when nil
+}
+
+// EndpointState tracks the load and passive health of one endpoint
+type EndpointState struct {
+	Endpoint
+
+	url      *urlMaker
+	inFlight int64
+	skew     int64 // measured clock skew in nanoseconds
+	offset   int64 // clock correction applied when signing, in nanoseconds
+
+	lock         sync.Mutex
+	failures     int           // consecutive failures
+	ejectedUntil time.Time     // out of selection until then
+	skewPending  bool          // a skew beyond the threshold waits for a second measurement
+	skewFirst    time.Duration // the first of the two measurements
+}
+
+// InFlight returns the number of requests being sent to the endpoint
+func (s *EndpointState) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// Ejected reports whether the endpoint is out of selection after consecutive failures
+func (s *EndpointState) Ejected() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return time.Now().Before(s.ejectedUntil)
+}
+
+// EndpointSelector picks the endpoint of the next attempt among healthy candidates.
+// Candidates are never empty and keep the order of Config.Endpoints.
+type EndpointSelector interface {
+	Select(candidates []*EndpointState) *EndpointState
+}
+
+// RoundRobinSelector cycles through the candidates
+type RoundRobinSelector struct {
+	next uint64
+}
+
+// Select implements EndpointSelector
+func (s *RoundRobinSelector) Select(candidates []*EndpointState) *EndpointState {
+	n := atomic.AddUint64(&s.next, 1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+// WeightedSelector spreads requests in proportion to Endpoint.Weight with smooth weighted round-robin
+type WeightedSelector struct {
+	lock    sync.Mutex
+	current map[*EndpointState]int
+}
+
+// Select implements EndpointSelector
+func (s *WeightedSelector) Select(candidates []*EndpointState) *EndpointState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.current == nil {
+		s.current = make(map[*EndpointState]int)
+	}
+
+	var best *EndpointState
+	total := 0
+	for _, c := range candidates {
+		weight := c.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		s.current[c] += weight
+		if best == nil || s.current[c] > s.current[best] {
+			best = c
+		}
+	}
+	s.current[best] -= total
+	return best
+}
+
+// LeastInFlightSelector picks the candidate with the fewest requests in flight
+type LeastInFlightSelector struct {
+	roundRobin RoundRobinSelector
+}
+
+// Select implements EndpointSelector
+func (s *LeastInFlightSelector) Select(candidates []*EndpointState) *EndpointState {
+	least := make([]*EndpointState, 0, len(candidates))
+	min := int64(-1)
+	for _, c := range candidates {
+		n := c.InFlight()
+		if min < 0 || n < min {
+			min = n
+			least = least[:0]
+		}
+		if n == min {
+			least = append(least, c)
+		}
+	}
+	return s.roundRobin.Select(least)
+}
+
+// PrioritySelector sends every request to the healthy candidates of the lowest Endpoint.Priority,
+// failing over to the next priority once they are all ejected
+type PrioritySelector struct {
+	roundRobin RoundRobinSelector
+}
+
+// Select implements EndpointSelector
+func (s *PrioritySelector) Select(candidates []*EndpointState) *EndpointState {
+	var top []*EndpointState
+	for _, c := range candidates {
+		if len(top) > 0 && c.Priority > top[0].Priority {
+			continue
+		}
+		if len(top) > 0 && c.Priority < top[0].Priority {
+			top = top[:0]
+		}
+		top = append(top, c)
+	}
+	return s.roundRobin.Select(top)
+}
+
+// endpointPool selects endpoints and ejects the failing ones
+type endpointPool struct {
+	endpoints        []*EndpointState
+	selector         EndpointSelector
+	failureThreshold int
+	cooldown         time.Duration
+}
+
+func newEndpointPool(config *Config) (*endpointPool, error) {
+	endpoints := config.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []Endpoint{{URL: config.Endpoint}}
+	}
+
+	pool := &endpointPool{
+		selector:         config.EndpointSelector,
+		failureThreshold: config.EndpointFailureThreshold,
+		cooldown:         config.EndpointCooldown,
+	}
+	if pool.selector == nil {
+		pool.selector = &RoundRobinSelector{}
+	}
+	for _, endpoint := range endpoints {
+		um := &urlMaker{}
+		if err := um.Init(endpoint.URL); err != nil {
+			return nil, err
+		}
+		pool.endpoints = append(pool.endpoints, &EndpointState{Endpoint: endpoint, url: um})
+	}
+	return pool, nil
+}
+
+// pick selects an endpoint not in tried, ignoring ejected endpoints unless every endpoint is ejected
+func (pool *endpointPool) pick(tried []*EndpointState) *EndpointState {
+	var healthy, untried []*EndpointState
+	for _, ep := range pool.endpoints {
+		if containsEndpoint(tried, ep) {
+			continue
+		}
+		untried = append(untried, ep)
+		if !ep.Ejected() {
+			healthy = append(healthy, ep)
+		}
+	}
+
+	switch {
+	case len(healthy) > 0:
+		return pool.selector.Select(healthy)
+	case len(untried) > 0:
+		return pool.selector.Select(untried)
+	}
+	return pool.selector.Select(pool.endpoints)
+}
+
+// report records the outcome of a request, ejecting the endpoint after failureThreshold consecutive failures
+func (pool *endpointPool) report(ep *EndpointState, failed bool) {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	if !failed {
+		ep.failures = 0
+		return
+	}
+	ep.failures++
+	if pool.failureThreshold > 0 && ep.failures >= pool.failureThreshold {
+		ep.ejectedUntil = time.Now().Add(pool.cooldown)
+		ep.failures = 0
+	}
+}
+
+func containsEndpoint(endpoints []*EndpointState, ep *EndpointState) bool {
+	for _, e := range endpoints {
+		if e == ep {
+			return true
+		}
+	}
+	return false
+}
+
+// isEndpointFailure reports whether the outcome counts against the endpoint health
+func isEndpointFailure(resp *Response, err error) bool {
+	if resp == nil {
+		return err != nil
+	}
+	return resp.StatusCode >= 500
+}
+
+// canFailover reports whether a request that failed with a transport error may be sent again,
+// only idempotent requests and requests with an Idempotency-Key are resent unless the connection was never established
+func canFailover(method, idempotencyKey string, err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	if idempotencyKey != "" {
+		// the server deduplicates the attempts
+		return true
+	}
+	switch HTTPMethod(method) {
+	case HTTPGet, HTTPHead, HTTPPut, HTTPDelete:
+		return true
+	}
+	return false
+}
+
+// bodyRewinder returns a function seeking data back to its current offset, it fails for readers that can't seek
+func bodyRewinder(data io.Reader) func() error {
+	if data == nil {
+		return func() error { return nil }
+	}
+	seeker, ok := data.(io.Seeker)
+	if !ok {
+		return func() error { return errors.New("request body can't be rewound") }
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	return func() error {
+		if err != nil {
+			return err
+		}
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return err
+	}
+}
@@ -0,0 +1,65 @@
+package x_http_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWeightedSelector(t *testing.T) {
+	a := &EndpointState{Endpoint: Endpoint{URL: "a", Weight: 3}}
+	b := &EndpointState{Endpoint: Endpoint{URL: "b", Weight: 1}}
+	selector := &WeightedSelector{}
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		counts[selector.Select([]*EndpointState{a, b}).URL]++
+	}
+	if counts["a"] != 6 || counts["b"] != 2 {
+		t.Fatalf("unexpected distribution %v", counts)
+	}
+}
+
+func TestPrioritySelector(t *testing.T) {
+	primary := &EndpointState{Endpoint: Endpoint{URL: "primary", Priority: 0}}
+	backup := &EndpointState{Endpoint: Endpoint{URL: "backup", Priority: 1}}
+	selector := &PrioritySelector{}
+	if got := selector.Select([]*EndpointState{backup, primary}); got != primary {
+		t.Fatalf("Select = %s", got.URL)
+	}
+	if got := selector.Select([]*EndpointState{backup}); got != backup {
+		t.Fatalf("Select = %s", got.URL)
+	}
+}
+
+func TestEndpointFailover(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":404,"msg":"not found"}`))
+		}
+	}))
+	defer live.Close()
+
+	client, err := New("", "id", "secret",
+		Endpoints(Endpoint{URL: dead.URL, Priority: 0}, Endpoint{URL: live.URL, Priority: 1}),
+		EndpointSelection(&PrioritySelector{}, 1, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Conn.Do("POST", "/trade", nil, nil, nil, nil)
+	if err != nil || resp.Endpoint != live.URL {
+		t.Fatalf("Do: %v %+v", err, resp)
+	}
+	if !client.Conn.endpoints.endpoints[0].Ejected() {
+		t.Fatal("dead endpoint not ejected")
+	}
+
+	_, err = client.Conn.Do("GET", "/missing", nil, nil, nil, nil)
+	if srvErr, ok := err.(*ServiceError); !ok || srvErr.Endpoint != live.URL {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
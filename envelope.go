@@ -0,0 +1,107 @@
+package x_http_client
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Envelope is the business envelope {"code":0,"msg":"...","data":{...}} our services wrap every body in
+type Envelope struct {
+	Code      int             `json:"code"`
+	Message   string          `json:"msg"`
+	TrackID   string          `json:"track_id"`
+	RequestID string          `json:"request_id"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// envelopeMaxBody is the largest 2xx body Config.EnvelopeErrors reads to check its envelope,
+// larger bodies are returned unchecked without being read into memory
+const envelopeMaxBody = 1 << 20
+
+// envelopeError returns the *ServiceError of a JSON envelope whose numeric code is not a success code,
+// nil for other bodies
+func (conn Conn) envelopeError(body []byte, statusCode int, requestID string) *ServiceError {
+	var envelope struct {
+		Code      *int   `json:"code"`
+		Message   string `json:"msg"`
+		TrackID   string `json:"track_id"`
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(body, &envelope) != nil || envelope.Code == nil || conn.isEnvelopeSuccess(*envelope.Code) {
+		return nil
+	}
+	srvErr := &ServiceError{
+		Code:       *envelope.Code,
+		Message:    envelope.Message,
+		TrackID:    envelope.TrackID,
+		RequestID:  envelope.RequestID,
+		RawMessage: string(body),
+		StatusCode: statusCode,
+	}
+	if requestID != "" {
+		srvErr.RequestID = requestID
+	}
+	return srvErr
+}
+
+// isEnvelopeSuccess reports whether code is in Config.EnvelopeSuccessCodes, 0 is the only success code when empty
+func (conn Conn) isEnvelopeSuccess(code int) bool {
+	if len(conn.config.EnvelopeSuccessCodes) == 0 {
+		return code == 0
+	}
+	for _, c := range conn.config.EnvelopeSuccessCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// DoJSONEnvelope sends data encoded as JSON and decodes the data field of the envelope response into responseData.
+// A code outside Config.EnvelopeSuccessCodes is returned as *ServiceError, the envelope is kept on the response.
+func (conn Conn) DoJSONEnvelope(method, path string, params map[string]interface{}, headers map[string]string, data interface{}, responseData interface{}) (*JSONResponse, error) {
+	envelope := &Envelope{}
+	resp, err := conn.DoJSONResponse(method, path, params, headers, data, envelope)
+	if resp == nil || resp.BodyJSONError != nil {
+		return resp, err
+	}
+	resp.Envelope = envelope
+	if err != nil {
+		return resp, err
+	}
+
+	if !conn.isEnvelopeSuccess(envelope.Code) {
+		srvErr := &ServiceError{
+			Code:       envelope.Code,
+			Message:    envelope.Message,
+			TrackID:    envelope.TrackID,
+			RequestID:  envelope.RequestID,
+			Endpoint:   resp.Endpoint,
+			RawMessage: resp.GetBodyText(),
+			StatusCode: resp.StatusCode,
+			Method:     strings.ToUpper(method),
+		}
+		if srvErr.TrackID == "" {
+			srvErr.TrackID = resp.TrackID
+		}
+		if resp.RequestID != "" {
+			srvErr.RequestID = resp.RequestID
+		}
+		return resp, srvErr
+	}
+
+	if responseData == nil || len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return resp, nil
+	}
+	if err := json.Unmarshal(envelope.Data, responseData); err != nil {
+		resp.BodyJSONError = &DecodeError{
+			Method:      strings.ToUpper(method),
+			StatusCode:  resp.StatusCode,
+			RequestID:   resp.RequestID,
+			ContentType: resp.Headers.Get(HTTPHeaderContentType),
+			RawMessage:  resp.GetBodyText(),
+			Err:         err,
+		}
+	}
+	return resp, nil
+}
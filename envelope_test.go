@@ -0,0 +1,110 @@
+package x_http_client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type balance struct {
+	UID    int64 `json:"uid"`
+	Amount int64 `json:"amount"`
+}
+
+func newEnvelopeServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HTTPHeaderContentType, "application/json")
+		w.Header().Set(HTTPHeaderTrackID, "track-1")
+		switch r.URL.Path {
+		case "/balance":
+			w.Write([]byte(`{"code":0,"msg":"ok","data":{"uid":7,"amount":100}}`))
+		case "/accepted":
+			w.Write([]byte(`{"code":201,"msg":"queued","data":{"uid":7}}`))
+		case "/frozen":
+			w.Write([]byte(`{"code":40031,"msg":"account frozen"}`))
+		case "/garbled":
+			w.Write([]byte(`{"code":0,"data":[1,2]}`))
+		case "/product":
+			w.Header().Set(HTTPHeaderContentType, "application/xml")
+			w.Write([]byte("<Product><Code>SKU-1</Code><Currency><Code>USD</Code></Currency></Product>"))
+		case "/rates":
+			w.Write([]byte(`[{"code":"USD"},{"code":"EUR"}]`))
+		case "/large":
+			w.Write([]byte(`{"code":40031,"msg":"` + strings.Repeat("x", envelopeMaxBody) + `"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDoJSONEnvelope(t *testing.T) {
+	client, err := New(newEnvelopeServer(t).URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b balance
+	resp, err := client.Conn.DoJSONEnvelope("GET", "/balance", nil, nil, nil, &b)
+	if err != nil || resp.BodyJSONError != nil {
+		t.Fatalf("DoJSONEnvelope: %v %v", err, resp.BodyJSONError)
+	}
+	if b.UID != 7 || b.Amount != 100 || resp.Envelope.Message != "ok" {
+		t.Fatalf("unexpected data %+v %+v", b, resp.Envelope)
+	}
+
+	_, err = client.Conn.DoJSONEnvelope("GET", "/frozen", nil, nil, nil, &b)
+	var srvErr *ServiceError
+	if !errors.As(err, &srvErr) || srvErr.Code != 40031 || srvErr.Message != "account frozen" ||
+		srvErr.TrackID != "track-1" || srvErr.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	_, err = client.Conn.DoJSONEnvelope("GET", "/accepted", nil, nil, nil, &b)
+	if !errors.As(err, &srvErr) || srvErr.Code != 201 {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	resp, err = client.Conn.DoJSONEnvelope("GET", "/garbled", nil, nil, nil, &b)
+	var decodeErr *DecodeError
+	if err != nil || !errors.As(resp.BodyJSONError, &decodeErr) {
+		t.Fatalf("unexpected result %v %v", err, resp.BodyJSONError)
+	}
+}
+
+func TestEnvelopeSuccessCodes(t *testing.T) {
+	client, err := New(newEnvelopeServer(t).URL, "id", "secret", EnvelopeSuccessCodes(0, 201), EnvelopeErrors())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b balance
+	if _, err := client.Conn.DoJSONEnvelope("GET", "/accepted", nil, nil, nil, &b); err != nil || b.UID != 7 {
+		t.Fatalf("unexpected result %v %+v", err, b)
+	}
+
+	resp, err := client.Conn.DoJSONEnvelope("GET", "/frozen", nil, nil, nil, &b)
+	var srvErr *ServiceError
+	if !errors.As(err, &srvErr) || srvErr.Code != 40031 || resp.Envelope == nil || resp.Envelope.Code != 40031 {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	// XML payloads and JSON without a numeric envelope code are not envelopes
+	for _, path := range []string{"/product", "/rates"} {
+		resp, err := client.Conn.Do("GET", path, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", path, err)
+		}
+		resp.Close()
+	}
+
+	// bodies over the limit are returned whole and unchecked
+	raw, err := client.Conn.Do("GET", "/large", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := raw.GetBodyText(); len(body) != envelopeMaxBody+len(`{"code":40031,"msg":""}`) {
+		t.Fatalf("unexpected body length %d", len(body))
+	}
+}
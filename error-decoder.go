@@ -0,0 +1,229 @@
+package x_http_client
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ServiceErrorDecoderFunc adapts a function to ServiceErrorDecoder
+type ServiceErrorDecoderFunc func(data []byte, srvErr *ServiceError) error
+
+// DecodeServiceError implements ServiceErrorDecoder
+func (f ServiceErrorDecoderFunc) DecodeServiceError(data []byte, srvErr *ServiceError) error {
+	return f(data, srvErr)
+}
+
+// Built-in error decoders
+var (
+	ProblemErrorDecoder ServiceErrorDecoder = problemErrorDecoder{} // RFC 7807 application/problem+json
+	XMLErrorDecoder     ServiceErrorDecoder = xmlErrorDecoder{}     // <Error><Code>...</Code><Message>...</Message></Error>
+)
+
+var (
+	errorDecodersLock sync.RWMutex
+	errorDecoders     = map[string]ServiceErrorDecoder{}
+)
+
+func init() {
+	RegisterErrorDecoder(ProblemErrorDecoder, "application/problem+json")
+	RegisterErrorDecoder(XMLErrorDecoder, "application/xml", "text/xml", "application/problem+xml")
+}
+
+// RegisterErrorDecoder registers decoder for the error bodies of the given content types.
+// Registered decoders take precedence over the codec of the content type.
+func RegisterErrorDecoder(decoder ServiceErrorDecoder, contentTypes ...string) {
+	errorDecodersLock.Lock()
+	defer errorDecodersLock.Unlock()
+	for _, ct := range contentTypes {
+		errorDecoders[mediaType(ct)] = decoder
+	}
+}
+
+// errorDecoderForContentType returns the registered error decoder of a Content-Type header value, or nil
+func errorDecoderForContentType(contentType string) ServiceErrorDecoder {
+	errorDecodersLock.RLock()
+	defer errorDecodersLock.RUnlock()
+	return errorDecoders[mediaType(contentType)]
+}
+
+// errorDecoder returns the error decoder configured for ep, nil to negotiate it from the Content-Type
+func (conn Conn) errorDecoder(ep *EndpointState) ServiceErrorDecoder {
+	if ep != nil && ep.ErrorDecoder != nil {
+		return ep.ErrorDecoder
+	}
+	return conn.config.ErrorDecoder
+}
+
+// isJSONType reports whether bodies of contentType are JSON and may carry an envelope
+func isJSONType(contentType string) bool {
+	mt := mediaType(contentType)
+	return mt == "application/json" || mt == "text/json" || strings.HasSuffix(mt, "+json")
+}
+
+// setErrorCode sets Code when the server sent a number and ErrorCode otherwise
+func setErrorCode(srvErr *ServiceError, code string) {
+	code = strings.TrimSpace(code)
+	if n, err := strconv.Atoi(code); err == nil {
+		srvErr.Code = n
+		return
+	}
+	srvErr.ErrorCode = code
+}
+
+// JSONPointerErrorDecoder decodes JSON error bodies of any shape, fields are located by RFC 6901 JSON pointers
+// such as "/error/code". Empty pointers are skipped, a numeric code goes to Code and any other to ErrorCode.
+type JSONPointerErrorDecoder struct {
+	Code      string
+	Message   string
+	TrackID   string
+	RequestID string
+}
+
+// DecodeServiceError implements ServiceErrorDecoder
+func (d JSONPointerErrorDecoder) DecodeServiceError(data []byte, srvErr *ServiceError) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+
+	if value, ok, err := jsonPointer(doc, d.Code); err != nil {
+		return err
+	} else if ok {
+		setErrorCode(srvErr, jsonString(value))
+	}
+	fields := []struct {
+		pointer string
+		value   *string
+	}{
+		{d.Message, &srvErr.Message},
+		{d.TrackID, &srvErr.TrackID},
+		{d.RequestID, &srvErr.RequestID},
+	}
+	for _, field := range fields {
+		value, ok, err := jsonPointer(doc, field.pointer)
+		if err != nil {
+			return err
+		}
+		if ok {
+			*field.value = jsonString(value)
+		}
+	}
+	return nil
+}
+
+// jsonPointer resolves pointer in doc, it reports false when pointer is empty or points nowhere
+func jsonPointer(doc interface{}, pointer string) (interface{}, bool, error) {
+	if pointer == "" {
+		return nil, false, nil
+	}
+	if pointer[0] != '/' {
+		return nil, false, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	value := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch node := value.(type) {
+		case map[string]interface{}:
+			next, ok := node[token]
+			if !ok {
+				return nil, false, nil
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false, nil
+			}
+			value = node[i]
+		default:
+			return nil, false, nil
+		}
+	}
+	return value, value != nil, nil
+}
+
+// jsonString formats a decoded JSON value, strings are unquoted and other values are encoded
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// problemErrorDecoder decodes RFC 7807 problem details, the problem type goes to ErrorCode
+type problemErrorDecoder struct{}
+
+func (problemErrorDecoder) DecodeServiceError(data []byte, srvErr *ServiceError) error {
+	var problem struct {
+		Type      string          `json:"type"`
+		Title     string          `json:"title"`
+		Detail    string          `json:"detail"`
+		Code      json.RawMessage `json:"code"`
+		TrackID   string          `json:"track_id"`
+		RequestID string          `json:"request_id"`
+	}
+	if err := json.Unmarshal(data, &problem); err != nil {
+		return err
+	}
+
+	srvErr.ErrorCode = problem.Type
+	if srvErr.ErrorCode == "" {
+		srvErr.ErrorCode = "about:blank"
+	}
+	if len(problem.Code) > 0 {
+		var code interface{}
+		if err := json.Unmarshal(problem.Code, &code); err == nil && code != nil {
+			if n, err := strconv.Atoi(jsonString(code)); err == nil {
+				srvErr.Code = n
+			}
+		}
+	}
+	switch {
+	case problem.Title != "" && problem.Detail != "":
+		srvErr.Message = problem.Title + ": " + problem.Detail
+	case problem.Detail != "":
+		srvErr.Message = problem.Detail
+	default:
+		srvErr.Message = problem.Title
+	}
+	srvErr.TrackID = problem.TrackID
+	srvErr.RequestID = problem.RequestID
+	return nil
+}
+
+// xmlErrorDecoder decodes XML errors whatever the root element, codes may be numbers or names such as NoSuchKey
+type xmlErrorDecoder struct{}
+
+func (xmlErrorDecoder) DecodeServiceError(data []byte, srvErr *ServiceError) error {
+	var body struct {
+		Code      string `xml:"Code"`
+		Message   string `xml:"Message"`
+		TrackID   string `xml:"TrackId"`
+		RequestID string `xml:"RequestId"`
+		HostID    string `xml:"HostId"`
+		Endpoint  string `xml:"Endpoint"`
+	}
+	if err := xml.Unmarshal(data, &body); err != nil {
+		return err
+	}
+
+	setErrorCode(srvErr, body.Code)
+	srvErr.Message = body.Message
+	srvErr.TrackID = body.TrackID
+	srvErr.RequestID = body.RequestID
+	srvErr.HostID = body.HostID
+	srvErr.Endpoint = body.Endpoint
+	return nil
+}
@@ -0,0 +1,140 @@
+package x_http_client
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorDecoders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/problem":
+			w.Header().Set(HTTPHeaderContentType, "application/problem+json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"type":"https://example.com/probs/out-of-credit","title":"Out of credit","detail":"balance is 30","status":403}`))
+		case "/xml":
+			w.Header().Set(HTTPHeaderContentType, "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>key missing</Message><RequestId>req-1</RequestId></Error>`))
+		case "/nested":
+			w.Header().Set(HTTPHeaderContentType, "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"1042","message":"bad amount","trace":"t-1"}}`))
+		case "/garbled":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>bad gateway</html>"))
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Conn.Do("GET", "/problem", nil, nil, nil, nil)
+	var srvErr *ServiceError
+	if !errors.As(err, &srvErr) || srvErr.ErrorCode != "https://example.com/probs/out-of-credit" ||
+		srvErr.Message != "Out of credit: balance is 30" || !errors.Is(err, ErrForbidden) {
+		t.Fatalf("unexpected problem error %#v", err)
+	}
+
+	_, err = client.Conn.Do("GET", "/xml", nil, nil, nil, nil)
+	if !errors.As(err, &srvErr) || srvErr.ErrorCode != "NoSuchKey" || srvErr.Message != "key missing" || srvErr.RequestID != "req-1" {
+		t.Fatalf("unexpected XML error %#v", err)
+	}
+
+	_, err = client.Conn.Do("GET", "/garbled", nil, nil, nil, nil)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.RawMessage != "<html>bad gateway</html>" {
+		t.Fatalf("unexpected decode error %#v", err)
+	}
+
+	pointers, err := New(server.URL, "id", "secret", ErrorDecoder(JSONPointerErrorDecoder{
+		Code:    "/error/code",
+		Message: "/error/message",
+		TrackID: "/error/trace",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pointers.Conn.Do("GET", "/nested", nil, nil, nil, nil)
+	if !errors.As(err, &srvErr) || srvErr.Code != 1042 || srvErr.Message != "bad amount" || srvErr.TrackID != "t-1" ||
+		srvErr.RawMessage == "" {
+		t.Fatalf("unexpected pointer error %#v", err)
+	}
+}
+
+func TestEndpointErrorDecoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"errno":7,"reason":"duplicate"}`))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", Endpoints(Endpoint{
+		URL:          server.URL,
+		ErrorDecoder: JSONPointerErrorDecoder{Code: "/errno", Message: "/reason"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Conn.Do("POST", "/orders", nil, nil, nil, nil)
+	var srvErr *ServiceError
+	if !errors.As(err, &srvErr) || srvErr.Code != 7 || srvErr.Message != "duplicate" || srvErr.Endpoint != server.URL {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestEnvelopeErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HTTPHeaderContentType, "application/json")
+		if r.URL.Path == "/fail" {
+			w.Write([]byte(`{"code":40012,"msg":"insufficient balance","track_id":"t-9"}`))
+			return
+		}
+		w.Write([]byte(`{"code":0,"msg":"ok","data":{"balance":1}}`))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", EnvelopeErrors())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Conn.Do("GET", "/fail", nil, nil, nil, nil)
+	var srvErr *ServiceError
+	if !errors.As(err, &srvErr) || srvErr.StatusCode != http.StatusOK || srvErr.Code != 40012 ||
+		srvErr.Message != "insufficient balance" || srvErr.TrackID != "t-9" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != srvErr.RawMessage {
+		t.Fatalf("body not restored: %q", body)
+	}
+
+	resp, err = client.Conn.Do("GET", "/ok", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	if string(body) != `{"code":0,"msg":"ok","data":{"balance":1}}` {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	var srvErr ServiceError
+	err := JSONPointerErrorDecoder{Code: "/errors/0/a~1b", Message: "/errors/0/m~0n"}.
+		DecodeServiceError([]byte(`{"errors":[{"a/b":"E_LIMIT","m~n":"limit reached"}]}`), &srvErr)
+	if err != nil || srvErr.ErrorCode != "E_LIMIT" || srvErr.Message != "limit reached" {
+		t.Fatalf("unexpected decode %v %#v", err, srvErr)
+	}
+	if err := (JSONPointerErrorDecoder{Code: "code"}).DecodeServiceError([]byte(`{}`), &srvErr); err == nil {
+		t.Fatal("invalid pointer accepted")
+	}
+}
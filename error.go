@@ -1,27 +1,216 @@
 package x_http_client
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Sentinel errors matched by errors.Is, a *ServiceError or *DecodeError matches the one of its status code
+var (
+	ErrBadRequest   = errors.New("bad request")  // 400
+	ErrUnauthorized = errors.New("unauthorized") // 401
+	ErrForbidden    = errors.New("forbidden")    // 403
+	ErrNotFound     = errors.New("not found")    // 404
+	ErrConflict     = errors.New("conflict")     // 409
+	ErrThrottled    = errors.New("throttled")    // 429
+	ErrServerError  = errors.New("server error") // 5xx
+	ErrTimeout      = errors.New("request timed out")
 )
 
 // ServiceError contains fields of the error response from Oss Service REST API.
 type ServiceError struct {
-	Code       int    `json:"code"`       // The error code returned from server to the caller
-	Message    string `json:"msg"`        // The detail error message from server
-	TrackID    string `json:"track_id"`   // The UUID used to uniquely identify the request
-	RequestID  string `json:"request_id"` // The UUID used to uniquely identify the request
-	HostID     string `json:"HostId"`     // The  server cluster's Id
-	Endpoint   string `json:"Endpoint"`
-	RawMessage string // The raw messages
-	StatusCode int    // HTTP status code
+	Code       int    `json:"code" xml:"Code"`            // The error code returned from server to the caller
+	ErrorCode  string `json:"-" xml:"-"`                  // Textual error code such as NoSuchKey or a problem type URI, set by error decoders
+	Message    string `json:"msg" xml:"Message"`          // The detail error message from server
+	TrackID    string `json:"track_id" xml:"TrackId"`     // The UUID used to uniquely identify the request
+	RequestID  string `json:"request_id" xml:"RequestId"` // The UUID used to uniquely identify the request
+	HostID     string `json:"HostId" xml:"HostId"`        // The  server cluster's Id
+	Endpoint   string `json:"Endpoint" xml:"Endpoint"`
+	RawMessage string `json:"-" xml:"-"` // The raw messages
+	StatusCode int    `json:"-" xml:"-"` // HTTP status code
+	Method     string `json:"-" xml:"-"` // HTTP method of the request
+	URL        string `json:"-" xml:"-"` // URL of the request
+	Attempt    int    `json:"-" xml:"-"` // Attempt of the request that got the error, from 1
 }
 
 // Error implements interface error
-func (e ServiceError) Error() string {
+func (e *ServiceError) Error() string {
+	code := strconv.Itoa(e.Code)
+	if e.ErrorCode != "" {
+		code = e.ErrorCode
+	}
 	if e.Endpoint == "" {
 		return fmt.Sprintf("service returned error: StatusCode=%d, ErrorCode=%s, ErrorMessage=\"%s\", RequestId=%s",
-			e.StatusCode, e.Code, e.Message, e.RequestID)
+			e.StatusCode, code, e.Message, e.RequestID)
 	}
 	return fmt.Sprintf("service returned error: StatusCode=%d, ErrorCode=%s, ErrorMessage=\"%s\", RequestId=%s, Endpoint=%s",
-		e.StatusCode, e.Code, e.Message, e.RequestID, e.Endpoint)
+		e.StatusCode, code, e.Message, e.RequestID, e.Endpoint)
+}
+
+// Is matches the sentinel error of the status code
+func (e *ServiceError) Is(target error) bool {
+	return statusIs(e.StatusCode, target)
+}
+
+// statusIs reports whether target is the sentinel error of statusCode
+func statusIs(statusCode int, target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return statusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return statusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return statusCode == http.StatusForbidden
+	case ErrNotFound:
+		return statusCode == http.StatusNotFound
+	case ErrConflict:
+		return statusCode == http.StatusConflict
+	case ErrThrottled:
+		return statusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return statusCode >= 500
+	}
+	return false
+}
+
+// TransportError is a request that got no response, such as a refused connection or a reset stream
+type TransportError struct {
+	Method   string
+	URL      string
+	Endpoint string
+	Attempt  int
+	Err      error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("transport error: Method=%s, URL=%s, Attempt=%d: %v", e.Method, e.URL, e.Attempt, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is a request that timed out or outlived its context deadline
+type TimeoutError struct {
+	Method   string
+	URL      string
+	Endpoint string
+	Attempt  int
+	Err      error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("request timed out: Method=%s, URL=%s, Attempt=%d: %v", e.Method, e.URL, e.Attempt, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// Timeout implements net.Error
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// DecodeError is a response body that could not be decoded, the error body of a failed request or the body of DoCodecResponse
+type DecodeError struct {
+	Method      string
+	URL         string
+	Attempt     int
+	StatusCode  int
+	RequestID   string
+	ContentType string
+	RawMessage  string // The undecoded body
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("service returned invalid response body: StatusCode=%d, ContentType=%s, RequestId=%s: %v",
+		e.StatusCode, e.ContentType, e.RequestID, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the sentinel error of the status code, like ServiceError does
+func (e *DecodeError) Is(target error) bool {
+	return statusIs(e.StatusCode, target)
+}
+
+// RedirectError is a 3xx response that was not followed
+type RedirectError struct {
+	Method     string
+	URL        string
+	Attempt    int
+	StatusCode int
+	Location   string
+	RequestID  string
+	RawMessage string // The body of the redirect response
+	Err        error  // Why the redirect was not followed, nil when redirects are disabled
+}
+
+func (e *RedirectError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("service returned %d redirect to %s: RequestId=%s: %v", e.StatusCode, e.Location, e.RequestID, e.Err)
+	}
+	return fmt.Sprintf("service returned %d redirect to %s: RequestId=%s", e.StatusCode, e.Location, e.RequestID)
+}
+
+func (e *RedirectError) Unwrap() error {
+	return e.Err
+}
+
+// SignatureError is a request that could not be signed
+type SignatureError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("sign request failed: Method=%s, URL=%s: %v", e.Method, e.URL, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// annotateError adds the request details to err, errors without a type become a *TimeoutError or a *TransportError
+func annotateError(err error, method, uri, endpoint string, attempt int) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *ServiceError:
+		e.Method, e.URL, e.Endpoint, e.Attempt = method, uri, endpoint, attempt
+		return e
+	case *DecodeError:
+		e.Method, e.URL, e.Attempt = method, uri, attempt
+		return e
+	case *RedirectError:
+		e.Method, e.URL, e.Attempt = method, uri, attempt
+		return e
+	case *SignatureError, *TimeoutError, *TransportError:
+		return err
+	}
+	if isTimeout(err) {
+		return &TimeoutError{Method: method, URL: uri, Endpoint: endpoint, Attempt: attempt, Err: err}
+	}
+	return &TransportError{Method: method, URL: uri, Endpoint: endpoint, Attempt: attempt, Err: err}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
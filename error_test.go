@@ -0,0 +1,123 @@
+package x_http_client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type failingCredentialsProvider struct{}
+
+func (failingCredentialsProvider) GetCredentials() Credentials { return nil }
+
+func (failingCredentialsProvider) LoadCredentials() (Credentials, error) {
+	return nil, errors.New("sts unavailable")
+}
+
+func TestTypedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.Header().Set(HTTPHeaderRequestID, "req-404")
+			w.WriteHeader(http.StatusNotFound)
+		case "/garbled":
+			w.Header().Set(HTTPHeaderContentType, "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>bad gateway</html>"))
+		case "/moved":
+			http.Redirect(w, r, "/elsewhere", http.StatusMovedPermanently)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Conn.Do("GET", "/missing", nil, nil, nil, nil)
+	var srvErr *ServiceError
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &srvErr) ||
+		srvErr.Method != "GET" || srvErr.URL != server.URL+"/missing" || srvErr.Attempt != 1 || srvErr.RequestID != "req-404" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	_, err = client.Conn.Do("GET", "/garbled", nil, nil, nil, nil)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	_, err = client.Conn.Do("GET", "/moved", nil, nil, nil, nil)
+	var redirectErr *RedirectError
+	if !errors.As(err, &redirectErr) || redirectErr.Location != "/elsewhere" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Conn.DoWithContext(ctx, "GET", "/slow", nil, nil, nil, nil)
+	var timeoutErr *TimeoutError
+	if !errors.Is(err, ErrTimeout) || !errors.As(err, &timeoutErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	client.Config.CredentialsProvider = failingCredentialsProvider{}
+	_, err = client.Conn.Do("GET", "/missing", nil, nil, nil, nil)
+	var signErr *SignatureError
+	if !errors.As(err, &signErr) {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Conn.Do("GET", "/", nil, nil, nil, nil)
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.Endpoint != server.URL || errors.Is(err, ErrTimeout) {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestDecodeErrorMatchesStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HTTPHeaderContentType, "text/html")
+		if r.URL.Path == "/gateway" {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<html><body>404 Not Found</body></html>"))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Config.RetryTimes = 0
+
+	_, err = client.Conn.Do("GET", "/missing", nil, nil, nil, nil)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || !errors.Is(err, ErrNotFound) || errors.Is(err, ErrServerError) ||
+		decodeErr.RawMessage != "<html><body>404 Not Found</body></html>" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	_, err = client.Conn.Do("GET", "/gateway", nil, nil, nil, nil)
+	if !errors.As(err, &decodeErr) || !errors.Is(err, ErrServerError) || errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/872409/ghttpclient
 
-go 1.16
\ No newline at end of file
+go 1.24
@@ -0,0 +1,257 @@
+package x_http_client
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgingConfig defines when idempotent GET and HEAD requests without body are hedged:
+// a duplicate attempt is sent when the first one is slow, the first successful response wins.
+type HedgingConfig struct {
+	Delay       time.Duration // Wait before hedging, used while fewer than MinSamples latencies are known or when Percentile is 0
+	Percentile  float64       // Hedge once the attempt is slower than this percentile of recent latencies, such as 0.95
+	MinSamples  int           // Latencies needed before Percentile applies, 20 by default
+	MaxHedges   int           // Extra attempts per request, 1 by default
+	BudgetRatio float64       // Hedges allowed per request on average such as 0.1, 0 for no budget
+}
+
+const (
+	hedgeLatencySamples = 200 // latencies kept for the percentile
+	hedgeBudgetBurst    = 10  // hedges that can be saved up in the budget
+)
+
+// hedger schedules hedged attempts and keeps their budget
+type hedger struct {
+	config *HedgingConfig
+
+	lock      sync.Mutex
+	latencies []time.Duration // ring buffer of recent latencies
+	next      int
+	budget    float64
+}
+
+func newHedger(config *HedgingConfig) *hedger {
+	if config == nil {
+		return nil
+	}
+	return &hedger{config: config, budget: 1}
+}
+
+// applies reports whether req may be hedged
+func (h *hedger) applies(req *request) bool {
+	if h == nil || req.data != nil {
+		return false
+	}
+	return req.method == string(HTTPGet) || req.method == string(HTTPHead)
+}
+
+// delay returns the time to wait before the next hedge, or false to not hedge
+func (h *hedger) delay() (time.Duration, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	minSamples := h.config.MinSamples
+	if minSamples <= 0 {
+		minSamples = 20
+	}
+	if h.config.Percentile > 0 && len(h.latencies) >= minSamples {
+		sorted := make([]time.Duration, len(h.latencies))
+		copy(sorted, h.latencies)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		i := int(h.config.Percentile * float64(len(sorted)-1))
+		return sorted[i], true
+	}
+	return h.config.Delay, h.config.Delay > 0
+}
+
+// deposit credits the budget for one request
+func (h *hedger) deposit() {
+	if h.config.BudgetRatio <= 0 {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.budget += h.config.BudgetRatio
+	if h.budget > hedgeBudgetBurst {
+		h.budget = hedgeBudgetBurst
+	}
+}
+
+// withdraw takes one hedge from the budget
+func (h *hedger) withdraw() bool {
+	if h.config.BudgetRatio <= 0 {
+		return true
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.budget < 1 {
+		return false
+	}
+	h.budget--
+	return true
+}
+
+func (h *hedger) observe(latency time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if len(h.latencies) < hedgeLatencySamples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeLatencySamples
+}
+
+func (h *hedger) maxHedges() int {
+	if h.config.MaxHedges <= 0 {
+		return 1
+	}
+	return h.config.MaxHedges
+}
+
+// hedgeEndpoints lists the endpoints taken by the attempts of one hedged request,
+// so every hedge goes to another endpoint while there is one
+type hedgeEndpoints struct {
+	lock      sync.Mutex
+	endpoints []*EndpointState
+}
+
+func (he *hedgeEndpoints) add(ep *EndpointState) {
+	if he == nil {
+		return
+	}
+	he.lock.Lock()
+	defer he.lock.Unlock()
+	he.endpoints = append(he.endpoints, ep)
+}
+
+func (he *hedgeEndpoints) inUse() []*EndpointState {
+	if he == nil {
+		return nil
+	}
+	he.lock.Lock()
+	defer he.lock.Unlock()
+	return append([]*EndpointState(nil), he.endpoints...)
+}
+
+type hedgeResult struct {
+	id    int
+	resp  *Response
+	err   error
+	start time.Time
+}
+
+// final reports whether the attempt settles the request, server errors and transport errors wait for the hedges
+func (r hedgeResult) final() bool {
+	return r.err == nil || (r.resp != nil && r.resp.StatusCode < 500)
+}
+
+// discard closes the body of a losing attempt
+func (r hedgeResult) discard() {
+	if r.resp != nil && r.resp.Body != nil {
+		r.resp.Body.Close()
+	}
+}
+
+// sendHedged sends the request and hedges it after the hedging delay, returning the first final response.
+// The other attempts are canceled and their bodies closed.
+func (conn Conn) sendHedged(req *request) (*Response, error) {
+	h := conn.hedger
+	h.deposit()
+
+	results := make(chan hedgeResult, h.maxHedges()+1)
+	endpoints := &hedgeEndpoints{}
+	var cancels []context.CancelFunc
+	launch := func() {
+		ctx, cancel := context.WithCancel(req.ctx)
+		cancels = append(cancels, cancel)
+		attempt := *req
+		attempt.ctx = ctx
+		attempt.hedges = endpoints
+		id, start := len(cancels)-1, time.Now()
+		go func() {
+			resp, err := conn.sendLimited(&attempt)
+			results <- hedgeResult{id: id, resp: resp, err: err, start: start}
+		}()
+	}
+	// cancelOthers cancels every attempt but the winner
+	cancelOthers := func(winner int) {
+		for id, cancel := range cancels {
+			if id != winner {
+				cancel()
+			}
+		}
+	}
+
+	launch()
+	inFlight := 1
+	var timer <-chan time.Time
+	if delay, ok := h.delay(); ok {
+		timer = time.After(delay)
+	}
+
+	var last *hedgeResult
+	for inFlight > 0 {
+		select {
+		case <-timer:
+			timer = nil
+			if len(cancels) <= h.maxHedges() && h.withdraw() {
+				inFlight++
+				conn.config.WriteLog(Debug, "[Hedge]%s %s hedge %d\n", req.method, req.path, len(cancels))
+				launch()
+				if delay, ok := h.delay(); ok && len(cancels) <= h.maxHedges() {
+					timer = time.After(delay)
+				}
+			}
+		case result := <-results:
+			inFlight--
+			if last != nil {
+				last.discard()
+			}
+			if !result.final() {
+				last = &result
+				continue
+			}
+
+			h.observe(time.Since(result.start))
+			cancelOthers(result.id)
+			go func(n int) {
+				for i := 0; i < n; i++ {
+					(<-results).discard()
+				}
+			}(inFlight)
+			cancel := cancels[result.id]
+			if result.resp != nil && result.resp.Body != nil {
+				result.resp.Body = &cancelReadCloser{ReadCloser: result.resp.Body, cancel: cancel}
+			} else {
+				cancel()
+			}
+			return result.resp, result.err
+		}
+	}
+
+	// every attempt failed, the last failure is returned
+	cancelOthers(last.id)
+	cancel := cancels[last.id]
+	if last.resp != nil && last.resp.Body != nil {
+		last.resp.Body = &cancelReadCloser{ReadCloser: last.resp.Body, cancel: cancel}
+	} else {
+		cancel()
+	}
+	return last.resp, last.err
+}
+
+// cancelReadCloser releases the context of the returned attempt when its body is closed
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (rc *cancelReadCloser) Close() error {
+	err := rc.ReadCloser.Close()
+	rc.cancel()
+	return err
+}
@@ -0,0 +1,51 @@
+package x_http_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHedgedRequest(t *testing.T) {
+	canceled := make(chan struct{}, 1)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			canceled <- struct{}{}
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("quote"))
+	}))
+	defer fast.Close()
+
+	client, err := New("", "id", "secret",
+		Endpoints(Endpoint{URL: slow.URL}, Endpoint{URL: fast.URL}),
+		EndpointSelection(&PrioritySelector{}, 0, 0),
+		Hedging(HedgingConfig{Delay: 20 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := client.Conn.Do("GET", "/quote", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if resp.Endpoint != fast.URL || resp.GetBodyText() != "quote" {
+		t.Fatalf("unexpected response from %s: %q", resp.Endpoint, resp.GetBodyText())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("hedged request took %s", elapsed)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("slow attempt not canceled")
+	}
+}
@@ -0,0 +1,128 @@
+package x_http_client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// HTTP2Config enables HTTP/2, negotiated with ALPN over TLS or spoken with prior knowledge (h2c) over cleartext.
+// HTTP/2 multiplexes requests over one connection, so HTTPTimeout.ReadWriteTimeout applies to every read of a
+// response body instead of to the connection. The streams of a connection are limited by the server,
+// another connection is dialed once every connection reached the limit.
+type HTTP2Config struct {
+	Cleartext        bool          // Use h2c for http:// endpoints, HTTP/1.1 is then never used
+	MaxReadFrameSize int           // Largest frame accepted from the server, 0 for the Go default
+	PingInterval     time.Duration // Send a health check ping after this long without frames, 0 disables
+	PingTimeout      time.Duration // Close the connection when a ping is not answered in time, 15s by default
+	WriteByteTimeout time.Duration // Close the connection when no byte can be written for this long, 0 disables
+}
+
+// configureHTTP2 enables the protocols of config on transport
+func configureHTTP2(transport *http.Transport, config *HTTP2Config) {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(!config.Cleartext)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(config.Cleartext)
+	transport.Protocols = protocols
+	transport.HTTP2 = &http.HTTP2Config{
+		MaxReadFrameSize: config.MaxReadFrameSize,
+		SendPingTimeout:  config.PingInterval,
+		PingTimeout:      config.PingTimeout,
+		WriteByteTimeout: config.WriteByteTimeout,
+	}
+}
+
+// newDialTLSContext returns the TLS dial function of transports negotiating HTTP/2. Deadlines on a multiplexed
+// connection would fail every stream, so they are lifted once h2 is negotiated and HTTP/1.1 fallbacks keep them.
+func newDialTLSContext(config *Config, resolver *hostResolver, tlsConfig func() *tls.Config, timeout, longTimeout time.Duration) func(ctx context.Context, netw, addr string) (net.Conn, error) {
+	dial := newDialContext(config, resolver, timeout, longTimeout)
+	return func(ctx context.Context, netw, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, netw, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := &tls.Config{}
+		if c := tlsConfig(); c != nil {
+			cfg = c.Clone()
+		}
+		if cfg.ServerName == "" {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				host = addr
+			}
+			cfg.ServerName = host
+		}
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if tc, ok := conn.(*timeoutConn); ok && tlsConn.ConnectionState().NegotiatedProtocol == "h2" {
+			tc.timeout, tc.longTimeout = 0, 0
+			tc.conn.SetDeadline(time.Time{})
+		}
+		return tlsConn, nil
+	}
+}
+
+// bodyReadTimeout cancels the request when a read of its response body takes longer than timeout
+type bodyReadTimeout struct {
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+func newBodyReadTimeout(ctx context.Context, timeout time.Duration) (context.Context, *bodyReadTimeout) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, &bodyReadTimeout{timeout: timeout, cancel: cancel}
+}
+
+// wrap applies the timeout to the reads of body, a nil bodyReadTimeout returns body as is
+func (t *bodyReadTimeout) wrap(body io.ReadCloser) io.ReadCloser {
+	if t == nil {
+		return body
+	}
+	return &readTimeoutBody{ReadCloser: body, timeout: t.timeout, cancel: t.cancel}
+}
+
+// release cancels the request context when no response body takes it over
+func (t *bodyReadTimeout) release() {
+	if t != nil {
+		t.cancel()
+	}
+}
+
+// readTimeoutBody fails a read that takes longer than timeout by canceling the request
+type readTimeoutBody struct {
+	io.ReadCloser
+	timeout  time.Duration
+	cancel   context.CancelFunc
+	timedOut int32
+}
+
+func (b *readTimeoutBody) Read(p []byte) (int, error) {
+	timer := time.AfterFunc(b.timeout, func() {
+		atomic.StoreInt32(&b.timedOut, 1)
+		b.cancel()
+	})
+	n, err := b.ReadCloser.Read(p)
+	if !timer.Stop() && atomic.LoadInt32(&b.timedOut) == 1 {
+		err = fmt.Errorf("read response body: %w", os.ErrDeadlineExceeded)
+	}
+	return n, err
+}
+
+func (b *readTimeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,125 @@
+package x_http_client
+
+import (
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHTTP2(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(r.ProtoMajor)))
+	})
+
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})
+
+	h2cServer := httptest.NewUnstartedServer(handler)
+	h2cServer.Config.Protocols = new(http.Protocols)
+	h2cServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2cServer.Start()
+	defer h2cServer.Close()
+
+	tlsClient, err := New(tlsServer.URL, "id", "secret", TLS(TLSConfig{RootCAPEM: caPEM}), HTTP2(HTTP2Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2cClient, err := New(h2cServer.URL, "id", "secret", HTTP2(HTTP2Config{Cleartext: true, PingInterval: time.Second}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, client := range map[string]*Client{"tls": tlsClient, "h2c": h2cClient} {
+		resp, err := client.Conn.Do("GET", "/", nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if proto := resp.GetBodyText(); proto != "2" {
+			t.Fatalf("%s: served over HTTP/%s", name, proto)
+		}
+		resp.Close()
+	}
+}
+
+func TestHTTP2BodyReadTimeout(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", HTTP2(HTTP2Config{Cleartext: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Config.HTTPTimeout.ReadWriteTimeout = 100 * time.Millisecond
+
+	resp, err := client.Conn.Do("GET", "/", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	start := time.Now()
+	_, err = ioutil.ReadAll(resp)
+	if !errors.Is(err, os.ErrDeadlineExceeded) || time.Since(start) > time.Second {
+		t.Fatalf("expected a read timeout, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestHTTP2FallbackKeepsDeadlines(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte(strconv.Itoa(r.ProtoMajor)))
+	})
+	h1Server := httptest.NewTLSServer(handler)
+	defer h1Server.Close()
+	h2Server := httptest.NewUnstartedServer(handler)
+	h2Server.EnableHTTP2 = true
+	h2Server.StartTLS()
+	defer h2Server.Close()
+
+	// the wait for the response is bounded by LongTimeout on HTTP/1.1 connections
+	shortReads := func(client *Client) {
+		client.Config.HTTPTimeout.LongTimeout = 100 * time.Millisecond
+	}
+	for _, server := range []*httptest.Server{h1Server, h2Server} {
+		caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		client, err := New(server.URL, "id", "secret", shortReads, TLS(TLSConfig{RootCAPEM: caPEM}), HTTP2(HTTP2Config{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Conn.Do("GET", "/", nil, nil, nil, nil)
+		if server == h1Server {
+			// the HTTP/1.1 connection times out waiting for the response
+			if err == nil {
+				resp.Close()
+				t.Fatal("expected a read timeout over HTTP/1.1")
+			}
+			continue
+		}
+		// the h2 connection has no deadline, the stream waits for the response
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proto := resp.GetBodyText(); proto != "2" {
+			t.Fatalf("served over HTTP/%s", proto)
+		}
+		resp.Close()
+	}
+}
@@ -0,0 +1,46 @@
+package x_http_client
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// idempotencyKey returns the headers of an unsafe request carrying an Idempotency-Key, the one given by the caller
+// or a generated one when Config.IdempotencyKeys is set. The caller's headers map is not modified.
+func (conn Conn) idempotencyKey(method string, headers map[string]string) (map[string]string, string) {
+	if key := headerValue(headers, HTTPHeaderIdempotencyKey); key != "" {
+		return headers, key
+	}
+	if !conn.config.IdempotencyKeys {
+		return headers, ""
+	}
+	switch HTTPMethod(method) {
+	case HTTPPost, HTTPPut, HTTPPatch, HTTPDelete:
+	default:
+		return headers, ""
+	}
+
+	key, err := newUUID()
+	if err != nil {
+		conn.config.WriteLog(Warn, "[Idempotency]generate key failed:%s\n", err.Error())
+		return headers, ""
+	}
+	withKey := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		withKey[k] = v
+	}
+	withKey[HTTPHeaderIdempotencyKey] = key
+	conn.config.WriteLog(Debug, "[Idempotency]%s %s\n", method, key)
+	return withKey, key
+}
+
+// newUUID returns a random UUID version 4
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
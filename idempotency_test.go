@@ -0,0 +1,61 @@
+package x_http_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyRetry(t *testing.T) {
+	keys := make(chan string, 2)
+	// the first endpoint drops the connection after reading the request
+	dropping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get(HTTPHeaderIdempotencyKey)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer dropping.Close()
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get(HTTPHeaderIdempotencyKey)
+		if !strings.Contains(r.Header.Get(HTTPHeaderAuthorization), "idempotency-key,Signature") {
+			t.Errorf("Idempotency-Key not signed: %s", r.Header.Get(HTTPHeaderAuthorization))
+		}
+	}))
+	defer live.Close()
+
+	client, err := New("", "id", "secret",
+		Endpoints(Endpoint{URL: dropping.URL, Priority: 0}, Endpoint{URL: live.URL, Priority: 1}),
+		EndpointSelection(&PrioritySelector{}, 0, time.Minute),
+		IdempotencyKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Config.AuthVersion = AuthV2
+	client.Config.AdditionalHeaders = []string{HTTPHeaderHost}
+
+	resp, err := client.Conn.Do("POST", "/account/trade/withdraw/pre", nil, nil, strings.NewReader("{}"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+	if resp.Endpoint != live.URL || len(resp.IdempotencyKey) != 36 {
+		t.Fatalf("unexpected response from %s with key %q", resp.Endpoint, resp.IdempotencyKey)
+	}
+	if first, second := <-keys, <-keys; first != resp.IdempotencyKey || second != resp.IdempotencyKey {
+		t.Fatalf("retry changed the key: %q, %q, %q", first, second, resp.IdempotencyKey)
+	}
+
+	// a caller-provided key is kept
+	resp, err = client.Conn.Do("POST", "/account/trade/withdraw/pre", nil, map[string]string{HTTPHeaderIdempotencyKey: "order-1"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+	if resp.IdempotencyKey != "order-1" {
+		t.Fatalf("caller key replaced by %q", resp.IdempotencyKey)
+	}
+}
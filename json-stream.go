@@ -0,0 +1,147 @@
+package x_http_client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// JSONDecodeOptions configures the json.Decoder of JSONStream
+type JSONDecodeOptions struct {
+	DisallowUnknownFields bool // Fail on object keys that don't match a destination field
+	UseNumber             bool // Decode numbers into json.Number instead of float64
+}
+
+// JSONStream decodes a JSON response body straight from the connection without buffering it.
+// The body is either decoded as a whole with Decode, or record by record with Next and Scan
+// when it is a top-level JSON array or newline delimited JSON.
+type JSONStream struct {
+	*Response
+	reader  *bufio.Reader
+	dec     *json.Decoder
+	started bool // first token inspected
+	inArray bool // body is a top-level array
+	done    bool
+	err     error
+}
+
+// DoJSONStream sends data encoded as JSON and returns the response body as a JSONStream.
+// The caller must Close the stream.
+func (conn Conn) DoJSONStream(method, path string, params map[string]interface{}, headers map[string]string, data interface{}, options *JSONDecodeOptions) (*JSONStream, error) {
+	body, err := encodeBody(JSONCodec, data)
+	if err != nil {
+		return nil, err
+	}
+
+	reqHeaders := make(map[string]string, len(headers)+2)
+	if body != nil {
+		reqHeaders[HTTPHeaderContentType] = JSONCodec.ContentType()
+	}
+	reqHeaders[HTTPHeaderAccept] = "application/json, application/x-ndjson"
+	for k, v := range headers {
+		reqHeaders[k] = v
+	}
+
+	resp, respErr := conn.Do(method, path, params, reqHeaders, body, nil)
+	if resp == nil {
+		return nil, respErr
+	}
+	return newJSONStream(resp, options), respErr
+}
+
+func newJSONStream(resp *Response, options *JSONDecodeOptions) *JSONStream {
+	reader := bufio.NewReader(resp.Body)
+	dec := json.NewDecoder(reader)
+	if options != nil {
+		if options.DisallowUnknownFields {
+			dec.DisallowUnknownFields()
+		}
+		if options.UseNumber {
+			dec.UseNumber()
+		}
+	}
+	return &JSONStream{Response: resp, reader: reader, dec: dec}
+}
+
+// Decode decodes the whole body into v
+func (s *JSONStream) Decode(v interface{}) error {
+	if s.started {
+		return errors.New("json stream: Decode called after Next")
+	}
+	s.started = true
+	s.done = true
+	s.err = s.dec.Decode(v)
+	return s.err
+}
+
+// Next reports whether another record is available for Scan.
+// It returns false at the end of the body or on error, check Err to tell them apart.
+func (s *JSONStream) Next() bool {
+	if s.err != nil || s.done {
+		return false
+	}
+
+	if !s.started {
+		s.started = true
+		first, err := peekNonSpace(s.reader)
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			s.done = true
+			return false
+		}
+		if first == '[' {
+			s.inArray = true
+			if _, err = s.dec.Token(); err != nil {
+				s.err = err
+				return false
+			}
+		}
+	}
+
+	if s.dec.More() {
+		return true
+	}
+	if s.inArray {
+		// consume the closing bracket
+		if _, err := s.dec.Token(); err != nil {
+			s.err = err
+		}
+	}
+	s.done = true
+	return false
+}
+
+// Scan decodes the current record into v
+func (s *JSONStream) Scan(v interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.dec.Decode(v); err != nil {
+		s.err = err
+	}
+	return s.err
+}
+
+// Err returns the first error met by Decode, Next or Scan
+func (s *JSONStream) Err() error {
+	return s.err
+}
+
+// peekNonSpace skips JSON whitespace and returns the next byte without consuming it
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			reader.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package x_http_client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type statementRecord struct {
+	ID     int         `json:"id"`
+	Amount json.Number `json:"amount"`
+}
+
+func TestDoJSONStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/array":
+			fmt.Fprint(w, ` [{"id":1,"amount":1.5},{"id":2,"amount":2}] `)
+		case "/ndjson":
+			fmt.Fprint(w, "{\"id\":1,\"amount\":1.5}\n{\"id\":2,\"amount\":2}\n")
+		case "/unknown":
+			fmt.Fprint(w, `{"id":1,"amount":1,"extra":true}`)
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/array", "/ndjson"} {
+		stream, err := client.Conn.DoJSONStream("GET", path, nil, nil, nil, &JSONDecodeOptions{UseNumber: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for stream.Next() {
+			var record statementRecord
+			if err := stream.Scan(&record); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, record.ID)
+		}
+		stream.Close()
+		if stream.Err() != nil || len(ids) != 2 || ids[1] != 2 {
+			t.Fatalf("%s: ids %v err %v", path, ids, stream.Err())
+		}
+	}
+
+	stream, err := client.Conn.DoJSONStream("GET", "/unknown", nil, nil, nil, &JSONDecodeOptions{DisallowUnknownFields: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if err := stream.Decode(&statementRecord{}); err == nil {
+		t.Fatal("expected unknown field error")
+	}
+}
@@ -0,0 +1,245 @@
+package x_http_client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when waiting for the rate limiter would outlive the context deadline
+var ErrRateLimited = errors.New("rate limit wait exceeds context deadline")
+
+// RateLimit caps the requests of the paths matching PathPattern
+type RateLimit struct {
+	PathPattern string  // path.Match pattern, a trailing * also matches deeper paths, empty matches every path
+	QPS         float64 // Token bucket refill rate, 0 for no QPS cap
+	Burst       int     // Token bucket size, QPS rounded up by default
+	MaxInFlight int     // Concurrent requests, 0 for no cap
+}
+
+// matches reports whether p is covered by the limit
+func (limit RateLimit) matches(p string) bool {
+	pattern := limit.PathPattern
+	if pattern == "" {
+		return true
+	}
+	if ok, _ := path.Match(pattern, p); ok {
+		return true
+	}
+	return strings.HasSuffix(pattern, "*") && strings.HasPrefix(p, strings.TrimSuffix(pattern, "*"))
+}
+
+// rateLimiter enforces one RateLimit
+type rateLimiter struct {
+	limit  RateLimit
+	bucket *tokenBucket
+	slots  chan struct{}
+}
+
+type rateLimiters []*rateLimiter
+
+func newRateLimiters(limits []RateLimit) rateLimiters {
+	var rls rateLimiters
+	for _, limit := range limits {
+		rl := &rateLimiter{limit: limit, bucket: newTokenBucket(limit.QPS, limit.Burst)}
+		if limit.MaxInFlight > 0 {
+			rl.slots = make(chan struct{}, limit.MaxInFlight)
+		}
+		rls = append(rls, rl)
+	}
+	return rls
+}
+
+// acquire waits for an in-flight slot, then a token, of every limit matching p.
+// The returned function gives the slots back, a failed wait gives back what was taken.
+func (rls rateLimiters) acquire(ctx context.Context, p string) (func(), error) {
+	var acquired []*rateLimiter
+	release := func() {
+		for _, rl := range acquired {
+			<-rl.slots
+		}
+	}
+
+	var matched []*rateLimiter
+	for _, rl := range rls {
+		if !rl.limit.matches(p) {
+			continue
+		}
+		matched = append(matched, rl)
+		if rl.slots == nil {
+			continue
+		}
+		select {
+		case rl.slots <- struct{}{}:
+			acquired = append(acquired, rl)
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	for i, rl := range matched {
+		if err := rl.bucket.wait(ctx); err != nil {
+			for _, taken := range matched[:i] {
+				taken.bucket.refund()
+			}
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// adapt pauses the limits matching p when the response says the quota is used up,
+// either with Retry-After on 429 and 503 or with X-RateLimit-Remaining: 0 and X-RateLimit-Reset
+func (rls rateLimiters) adapt(p string, resp *Response) {
+	if len(rls) == 0 || resp == nil {
+		return
+	}
+
+	var until time.Time
+	now := time.Now()
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		until = parseRetryAfter(resp.Headers.Get(HTTPHeaderRetryAfter), now)
+	}
+	if until.IsZero() && resp.Headers.Get(HTTPHeaderRateLimitRemaining) == "0" {
+		until = parseRateLimitReset(resp.Headers.Get(HTTPHeaderRateLimitReset), now)
+	}
+	if until.IsZero() {
+		return
+	}
+
+	for _, rl := range rls {
+		if rl.limit.matches(p) {
+			rl.bucket.pause(until)
+		}
+	}
+}
+
+// parseRetryAfter parses delay-seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// parseRateLimitReset parses a reset given as Unix seconds or as seconds from now
+func parseRateLimitReset(value string, now time.Time) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}
+	}
+	if seconds > 1000000000 {
+		return time.Unix(seconds, 0)
+	}
+	return now.Add(time.Duration(seconds) * time.Second)
+}
+
+// releaseReadCloser gives the in-flight slots back when the body is closed
+type releaseReadCloser struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (rc *releaseReadCloser) Close() error {
+	rc.once.Do(rc.release)
+	return rc.ReadCloser.Close()
+}
+
+// tokenBucket refills rate tokens per second up to burst, a zero rate never runs out
+type tokenBucket struct {
+	lock        sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token, or returns how long to wait before trying again
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+	if b.rate <= 0 {
+		return 0
+	}
+
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// wait blocks until a token is taken, failing fast when ctx would expire first
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		now := time.Now()
+		delay := b.reserve(now)
+		if delay <= 0 {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+			return ErrRateLimited
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// refund gives back a token taken by a request that was not sent
+func (b *tokenBucket) refund() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.rate > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+1)
+	}
+}
+
+// pause stops handing out tokens until the given time
+func (b *tokenBucket) pause(until time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if until.After(b.pausedUntil) {
+		b.pausedUntil = until
+		b.tokens = 0
+		b.last = until
+	}
+}
@@ -0,0 +1,119 @@
+package x_http_client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/partner/busy" {
+			w.Header().Set(HTTPHeaderRetryAfter, "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", RateLimits(
+		RateLimit{PathPattern: "/quote/*", QPS: 1, Burst: 1},
+		RateLimit{PathPattern: "/partner/*", MaxInFlight: 1},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := client.Conn.DoWithContext(ctx, "GET", "/quote/usd", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Conn.DoWithContext(ctx, "GET", "/quote/usd", nil, nil, nil, nil); err != ErrRateLimited {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if _, err := client.Conn.DoWithContext(ctx, "GET", "/account", nil, nil, nil, nil); err != nil {
+		t.Fatalf("unmatched path limited: %v", err)
+	}
+
+	resp, err := client.Conn.DoWithContext(ctx, "GET", "/partner/a", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Conn.DoWithContext(ctx, "GET", "/partner/b", nil, nil, nil, nil); err != context.DeadlineExceeded {
+		t.Fatalf("expected in-flight cap to block, got %v", err)
+	}
+	resp.Close()
+
+	if resp, _ = client.Conn.Do("GET", "/partner/busy", nil, nil, nil, nil); resp != nil {
+		resp.Close()
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := client.Conn.DoWithContext(ctx, "GET", "/partner/a", nil, nil, nil, nil); err != ErrRateLimited {
+		t.Fatalf("expected Retry-After pause, got %v", err)
+	}
+}
+
+func TestRateLimitFailedWaitKeepsTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", RateLimits(
+		RateLimit{QPS: 0.001, Burst: 3, MaxInFlight: 1},
+		RateLimit{PathPattern: "/quote/*", QPS: 0.001, Burst: 1},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Conn.Do("GET", "/account", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// waiting for the slot takes no token
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Conn.DoWithContext(ctx, "GET", "/account", nil, nil, nil, nil); err != context.DeadlineExceeded {
+		t.Fatalf("expected in-flight cap to block, got %v", err)
+	}
+	resp.Close()
+
+	// the token of the first limit is given back when the second runs out
+	if resp, err = client.Conn.Do("GET", "/quote/usd", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Conn.DoWithContext(ctx, "GET", "/quote/usd", nil, nil, nil, nil); err != ErrRateLimited {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if resp, err = client.Conn.DoWithContext(ctx, "GET", "/account", nil, nil, nil, nil); err != nil {
+		t.Fatalf("token not given back: %v", err)
+	}
+	resp.Close()
+}
+
+func TestRateLimitUnclosedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", RateLimits(RateLimit{MaxInFlight: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the bodies of failed requests are never closed
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		_, err := client.Conn.DoWithContext(ctx, "GET", "/missing", nil, nil, nil, nil)
+		cancel()
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("request %d: unexpected error %v", i, err)
+		}
+	}
+}
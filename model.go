@@ -11,6 +11,11 @@ type Response struct {
 	StatusCode int
 	RequestID  string
 	TrackID    string
+	Endpoint   string // Endpoint the request was sent to
+	FromCache  bool   // Served by Config.Cache, possibly after a 304 revalidation
+
+	IdempotencyKey string   // Idempotency-Key sent with the request, empty when none
+	Redirects      []string // URLs of the redirects followed, in order
 
 	Headers        http.Header
 	Body           io.ReadCloser
@@ -41,8 +46,16 @@ func (r *Response) GetBodyText() string {
 	return r.bodyText
 }
 
+// CodecResponse defines HTTP response whose body was decoded by Codec
+type CodecResponse struct {
+	*Response
+	Codec           Codec // Codec used to decode the body
+	BodyDecodeError error
+}
+
 type JSONResponse struct {
 	*Response
 	BodyJSONError error
+	Envelope      *Envelope // Business envelope decoded by DoJSONEnvelope
 	// BodyJSON      interface{}
 }
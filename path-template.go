@@ -0,0 +1,43 @@
+package x_http_client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ExpandPath replaces the {name} parameters of template, such as /account/{uid}/trade/{orderNo},
+// with their percent-escaped values, so a value never adds or removes path segments.
+// Empty, "." and ".." values are rejected, they would still change the path once it is cleaned.
+func ExpandPath(template string, params map[string]string) (string, error) {
+	var path strings.Builder
+	rest := template
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			if strings.IndexByte(rest, '}') >= 0 {
+				return "", fmt.Errorf("invalid path template %q, unmatched }", template)
+			}
+			path.WriteString(rest)
+			return path.String(), nil
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("invalid path template %q, unmatched {", template)
+		}
+		name := rest[open+1 : open+end]
+		if name == "" {
+			return "", fmt.Errorf("invalid path template %q, empty parameter name", template)
+		}
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("missing path parameter %q of template %q", name, template)
+		}
+		if value == "" || value == "." || value == ".." {
+			return "", fmt.Errorf("invalid path parameter %s=%q of template %q", name, value, template)
+		}
+		path.WriteString(rest[:open])
+		path.WriteString(url.PathEscape(value))
+		rest = rest[open+end+1:]
+	}
+}
@@ -0,0 +1,53 @@
+package x_http_client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	path, err := ExpandPath("/account/{uid}/trade/{orderNo}", map[string]string{"uid": "42", "orderNo": "a/b c"})
+	if err != nil || path != "/account/42/trade/a%2Fb%20c" {
+		t.Fatalf("unexpected path %q: %v", path, err)
+	}
+	for _, template := range []string{"/account/{uid", "/account/uid}", "/account/{missing}", "/account/{}"} {
+		if _, err := ExpandPath(template, map[string]string{"uid": "42", "": "42"}); err == nil {
+			t.Fatalf("expected an error for %q", template)
+		}
+	}
+	for _, value := range []string{"", ".", ".."} {
+		if path, err := ExpandPath("/account/{uid}/trade", map[string]string{"uid": value}); err == nil {
+			t.Fatalf("expected an error for %q, got %q", value, path)
+		}
+	}
+	if path, err := ExpandPath("/trade/{orderNo}", map[string]string{"orderNo": "..."}); err != nil || path != "/trade/..." {
+		t.Fatalf("unexpected path %q: %v", path, err)
+	}
+}
+
+func TestBasePathAndInvalidURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.EscapedPath()))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL+"/api/v2/", "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Conn.Send(context.Background(), "GET", "/account/{uid}/trade/{orderNo}",
+		WithPathParam("uid", "42"), WithPathParam("orderNo", "T/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if path := resp.GetBodyText(); path != "/api/v2/account/42/trade/T%2F1" {
+		t.Fatalf("unexpected path %q", path)
+	}
+
+	if _, err := client.Conn.Do("GET", "/trade/%zz", nil, nil, nil, nil); err == nil {
+		t.Fatal("expected an error for an invalid URL")
+	}
+}
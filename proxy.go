@@ -0,0 +1,84 @@
+package x_http_client
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// ProxyCredentials is the user and password presented to a proxy
+type ProxyCredentials interface {
+	GetProxyUser() string
+	GetProxyPassword() string
+}
+
+// ProxyCredentialsProvider returns the credentials of a proxy, nil when the proxy needs none
+type ProxyCredentialsProvider interface {
+	GetProxyCredentials(proxy *url.URL) ProxyCredentials
+}
+
+type defaultProxyCredentials struct {
+	config *Config
+}
+
+func (defCre *defaultProxyCredentials) GetProxyUser() string {
+	return defCre.config.ProxyUser
+}
+
+func (defCre *defaultProxyCredentials) GetProxyPassword() string {
+	return defCre.config.ProxyPassword
+}
+
+// defaultProxyCredentialsProvider returns ProxyUser and ProxyPassword when IsAuthProxy is set
+type defaultProxyCredentialsProvider struct {
+	config *Config
+}
+
+func (defBuild *defaultProxyCredentialsProvider) GetProxyCredentials(proxy *url.URL) ProxyCredentials {
+	if !defBuild.config.IsAuthProxy {
+		return nil
+	}
+	return &defaultProxyCredentials{config: defBuild.config}
+}
+
+// newProxyFunc returns the proxy selection of the transport: Config.ProxyFunc, the static ProxyHost or the
+// environment, in that order. The selected proxy gets the credentials of ProxyCredentialsProvider when its URL
+// has none, for HTTP proxies as well as SOCKS5 ones. It returns nil when requests go direct.
+func newProxyFunc(config *Config) (func(*http.Request) (*url.URL, error), error) {
+	var selectProxy func(*http.Request) (*url.URL, error)
+	switch {
+	case config.ProxyFunc != nil:
+		selectProxy = config.ProxyFunc
+	case config.IsUseProxy:
+		proxyURL, err := url.Parse(config.ProxyHost)
+		if err != nil {
+			return nil, err
+		}
+		selectProxy = http.ProxyURL(proxyURL)
+	case config.ProxyFromEnvironment:
+		selectProxy = http.ProxyFromEnvironment
+	default:
+		return nil, nil
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if isUnixHost(req.URL.Host) {
+			// unix:// endpoints are local
+			return nil, nil
+		}
+		proxyURL, err := selectProxy(req)
+		if err != nil || proxyURL == nil || proxyURL.User != nil || config.ProxyCredentialsProvider == nil {
+			return proxyURL, err
+		}
+		creds := config.ProxyCredentialsProvider.GetProxyCredentials(proxyURL)
+		if creds == nil {
+			return proxyURL, nil
+		}
+		withUser := *proxyURL
+		if creds.GetProxyPassword() != "" {
+			withUser.User = url.UserPassword(creds.GetProxyUser(), creds.GetProxyPassword())
+		} else {
+			withUser.User = url.User(creds.GetProxyUser())
+		}
+		return &withUser, nil
+	}, nil
+}
@@ -0,0 +1,195 @@
+package x_http_client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+type testProxyCredentials struct {
+	user, password string
+}
+
+func (c *testProxyCredentials) GetProxyUser() string     { return c.user }
+func (c *testProxyCredentials) GetProxyPassword() string { return c.password }
+
+type testProxyCredentialsProvider struct{}
+
+func (testProxyCredentialsProvider) GetProxyCredentials(proxy *url.URL) ProxyCredentials {
+	return &testProxyCredentials{user: "proxy-user", password: "proxy-secret"}
+}
+
+func TestProxySelector(t *testing.T) {
+	proxied := make(chan *http.Request, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	client, err := New("http://gateway.internal", "id", "secret",
+		ProxySelector(func(req *http.Request) (*url.URL, error) {
+			if req.URL.Hostname() == "gateway.internal" {
+				return proxyURL, nil
+			}
+			return nil, nil
+		}),
+		ProxyAuth(testProxyCredentialsProvider{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Conn.Do("GET", "/quote", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if resp.GetBodyText() != "via proxy" {
+		t.Fatalf("unexpected body %q", resp.GetBodyText())
+	}
+	r := <-proxied
+	if r.URL.String() != "http://gateway.internal/quote" {
+		t.Fatalf("unexpected proxied URL %s", r.URL)
+	}
+	// Basic base64("proxy-user:proxy-secret")
+	if auth := r.Header.Get("Proxy-Authorization"); auth != "Basic cHJveHktdXNlcjpwcm94eS1zZWNyZXQ=" {
+		t.Fatalf("unexpected Proxy-Authorization %q", auth)
+	}
+}
+
+func TestProxyCredentialsProvider(t *testing.T) {
+	auths := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths <- r.Header.Get("Proxy-Authorization")
+	}))
+	defer proxy.Close()
+
+	useProxy := func(client *Client) {
+		client.Config.IsUseProxy = true
+		client.Config.ProxyHost = proxy.URL
+	}
+	for _, tc := range []struct {
+		option ClientOption
+		auth   string
+	}{
+		{func(client *Client) {}, ""},
+		{func(client *Client) {
+			client.Config.IsAuthProxy = true
+			client.Config.ProxyUser, client.Config.ProxyPassword = "user", "pass"
+		}, "Basic dXNlcjpwYXNz"}, // base64("user:pass")
+		{ProxyAuth(testProxyCredentialsProvider{}), "Basic cHJveHktdXNlcjpwcm94eS1zZWNyZXQ="},
+	} {
+		client, err := New("http://gateway.internal", "id", "secret", useProxy, tc.option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Conn.Do("GET", "/quote", nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Close()
+		if auth := <-auths; auth != tc.auth {
+			t.Fatalf("unexpected Proxy-Authorization %q, want %q", auth, tc.auth)
+		}
+	}
+}
+
+// serveSOCKS5 accepts one CONNECT with user/password authentication (RFC 1928, RFC 1929) and
+// relays it to target, whatever host was asked for
+func serveSOCKS5(t *testing.T, listener net.Listener, user, password, target string, asked chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	read := func(n int) []byte {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Error(err)
+		}
+		return buf
+	}
+	// greeting: version, methods
+	hello := read(2)
+	if methods := read(int(hello[1])); hello[0] != 5 || bytes.IndexByte(methods, 2) < 0 {
+		t.Errorf("user/password not offered: %v", methods)
+		conn.Write([]byte{5, 0xff})
+		return
+	}
+	conn.Write([]byte{5, 2})
+	// user/password subnegotiation
+	read(1)
+	gotUser := string(read(int(read(1)[0])))
+	gotPassword := string(read(int(read(1)[0])))
+	if gotUser != user || gotPassword != password {
+		t.Errorf("unexpected credentials %s:%s", gotUser, gotPassword)
+		conn.Write([]byte{1, 1})
+		return
+	}
+	conn.Write([]byte{1, 0})
+	// request: version, CONNECT, reserved, address
+	header := read(4)
+	var host string
+	switch header[3] {
+	case 1:
+		host = net.IP(read(4)).String()
+	case 3:
+		host = string(read(int(read(1)[0])))
+	case 4:
+		host = net.IP(read(16)).String()
+	}
+	port := binary.BigEndian.Uint16(read(2))
+	asked <- net.JoinHostPort(host, strconv.Itoa(int(port)))
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+func TestSOCKS5ProxyAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + r.URL.Path))
+	}))
+	defer server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	asked := make(chan string, 1)
+	go serveSOCKS5(t, listener, "proxy-user", "proxy-secret", server.Listener.Addr().String(), asked)
+
+	client, err := New("http://gateway.internal", "id", "secret", ProxyAuth(testProxyCredentialsProvider{}),
+		func(client *Client) {
+			client.Config.IsUseProxy = true
+			client.Config.ProxyHost = "socks5://" + listener.Addr().String()
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Conn.Do("GET", "/quote", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if body := resp.GetBodyText(); body != "gateway.internal/quote" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if addr := <-asked; addr != "gateway.internal:80" {
+		t.Fatalf("unexpected CONNECT to %s", addr)
+	}
+}
@@ -0,0 +1,182 @@
+package x_http_client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTooManyRedirects is the RedirectError.Err of a request that exceeded RedirectPolicy.MaxHops
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// RedirectPolicy follows redirects and signs every hop again. Credentials are only sent to the host of the
+// request and TrustedHosts, other hosts and https to http downgrades get the request without them.
+type RedirectPolicy struct {
+	MaxHops      int      // Redirects followed per request, 10 by default
+	TrustedHosts []string // Other hosts trusted with credentials, "*.example.com" matches the subdomains
+}
+
+// redirectChain records the redirects followed by one request
+type redirectChain struct {
+	clockOffset time.Duration // clock correction of the endpoint the request was sent to
+
+	lock sync.Mutex
+	urls []string
+	err  error // why the last redirect was not followed
+}
+
+type redirectChainKey struct{}
+
+// withRedirectChain returns ctx recording the redirects followed by requests bound to it,
+// hops are dated with the clock corrected by clockOffset
+func withRedirectChain(ctx context.Context, clockOffset time.Duration) (context.Context, *redirectChain) {
+	chain := &redirectChain{clockOffset: clockOffset}
+	return context.WithValue(ctx, redirectChainKey{}, chain), chain
+}
+
+// redirects returns the URLs followed, nil when none
+func (c *redirectChain) redirects() []string {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.urls
+}
+
+// stopped returns why the last redirect was not followed
+func (c *redirectChain) stopped() error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+// redirectPolicy returns the policy of config, nil when redirects are not followed
+func redirectPolicy(config *Config) *RedirectPolicy {
+	if config.Redirect != nil {
+		return config.Redirect
+	}
+	if config.RedirectEnabled {
+		return &RedirectPolicy{}
+	}
+	return nil
+}
+
+// newCheckRedirect returns the http.Client CheckRedirect of policy, redirects are returned as is when policy is nil
+func newCheckRedirect(conn *Conn, policy *RedirectPolicy) func(req *http.Request, via []*http.Request) error {
+	if policy == nil {
+		return func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	maxHops := policy.MaxHops
+	if maxHops <= 0 {
+		maxHops = 10
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		chain, _ := req.Context().Value(redirectChainKey{}).(*redirectChain)
+		if len(via) > maxHops {
+			if chain != nil {
+				chain.lock.Lock()
+				chain.err = ErrTooManyRedirects
+				chain.lock.Unlock()
+			}
+			return http.ErrUseLastResponse
+		}
+
+		now := time.Now()
+		if chain != nil {
+			now = now.Add(chain.clockOffset)
+		}
+		if err := conn.resignRedirect(req, via, policy, now); err != nil {
+			if chain != nil {
+				chain.lock.Lock()
+				chain.err = err
+				chain.lock.Unlock()
+			}
+			return http.ErrUseLastResponse
+		}
+		if chain != nil {
+			chain.lock.Lock()
+			chain.urls = append(chain.urls, req.URL.String())
+			chain.lock.Unlock()
+		}
+		conn.config.WriteLog(Debug, "[Redirect]%s %s, hop %d\n", req.Method, req.URL.String(), len(via))
+		return nil
+	}
+}
+
+// resignRedirect stamps and signs the next hop, or strips the credentials when its host isn't trusted
+func (conn Conn) resignRedirect(req *http.Request, via []*http.Request, policy *RedirectPolicy, now time.Time) error {
+	first, prev := via[0], via[len(via)-1]
+	if req.Method != prev.Method || (prev.Body != nil && req.Body == nil) {
+		// 301, 302 and 303 turned the request into a GET without body
+		for _, name := range []string{HTTPHeaderContentType, HTTPHeaderContentMD5, HTTPHeaderContentLength, HTTPHeaderContentEncoding} {
+			req.Header.Del(name)
+		}
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	req.Header.Set(HTTPHeaderHost, host)
+	req.Header.Set(HTTPHeaderDate, now.UTC().Format(http.TimeFormat))
+
+	downgrade := first.URL.Scheme == "https" && req.URL.Scheme != "https"
+	if downgrade || (req.URL.Host != first.URL.Host && !policy.trusts(req.URL.Hostname())) {
+		req.Header.Del(HTTPHeaderAuthorization)
+		req.Header.Del(HTTPHeaderSecurityToken)
+		return nil
+	}
+
+	akIf, err := conn.config.LoadCredentials()
+	if err != nil {
+		return &SignatureError{Method: req.Method, URL: req.URL.String(), Err: err}
+	}
+	if akIf.GetSecurityToken() != "" {
+		req.Header.Set(HTTPHeaderSecurityToken, akIf.GetSecurityToken())
+	}
+	conn.signHeader(req, akIf)
+	return nil
+}
+
+// trusts reports whether host is one of TrustedHosts
+func (policy *RedirectPolicy) trusts(host string) bool {
+	host = strings.ToLower(host)
+	for _, trusted := range policy.TrustedHosts {
+		trusted = strings.ToLower(trusted)
+		if strings.HasPrefix(trusted, "*.") {
+			if strings.HasSuffix(host, trusted[1:]) {
+				return true
+			}
+		} else if host == trusted {
+			return true
+		}
+	}
+	return false
+}
+
+// getBody returns the GetBody of a request sending reader, so 307 and 308 redirects can send it again.
+// It is nil unless reader can be rewound.
+func getBody(reader io.Reader) func() (io.ReadCloser, error) {
+	if _, ok := reader.(io.Seeker); !ok {
+		return nil
+	}
+	rewind := bodyRewinder(reader)
+	return func() (io.ReadCloser, error) {
+		if err := rewind(); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(reader), nil
+	}
+}
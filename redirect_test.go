@@ -0,0 +1,124 @@
+package x_http_client
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirectResigns(t *testing.T) {
+	var auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get(HTTPHeaderAuthorization))
+		switch r.URL.Path {
+		case "/see-other":
+			http.Redirect(w, r, "/result", http.StatusSeeOther)
+		case "/temporary":
+			http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+		case "/result":
+			w.Write([]byte(r.Method))
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write([]byte(r.Method + " " + string(body)))
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", Redirects(RedirectPolicy{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Conn.Do("POST", "/see-other", nil, nil, strings.NewReader("order"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := resp.GetBodyText(); body != "GET" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if len(resp.Redirects) != 1 || resp.Redirects[0] != server.URL+"/result" {
+		t.Fatalf("unexpected redirects %v", resp.Redirects)
+	}
+	if len(auths) != 2 || auths[1] == "" {
+		t.Fatalf("redirect not signed: %q", auths)
+	}
+
+	resp, err = client.Conn.Do("POST", "/temporary", nil, nil, bytes.NewReader([]byte("order")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := resp.GetBodyText(); body != "POST order" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestRedirectCrossHost(t *testing.T) {
+	var auth string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get(HTTPHeaderAuthorization)
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", Redirects(RedirectPolicy{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Conn.Do("GET", "/go", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" {
+		t.Fatalf("credentials sent to another host: %q", auth)
+	}
+
+	trusting, err := New(server.URL, "id", "secret", Redirects(RedirectPolicy{TrustedHosts: []string{"127.0.0.1"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trusting.Conn.Do("GET", "/go", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(auth, "KT id:") {
+		t.Fatalf("trusted host not signed: %q", auth)
+	}
+}
+
+func TestRedirectMaxHops(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/permanent") {
+			http.Redirect(w, r, "/permanent", http.StatusPermanentRedirect)
+			return
+		}
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", Redirects(RedirectPolicy{MaxHops: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/loop", "/permanent"} {
+		resp, err := client.Conn.Do("GET", path, nil, nil, nil, nil)
+		var redirectErr *RedirectError
+		if !errors.As(err, &redirectErr) || !errors.Is(err, ErrTooManyRedirects) {
+			t.Fatalf("%s: unexpected error %#v", path, err)
+		}
+		if len(resp.Redirects) != 3 {
+			t.Fatalf("%s: unexpected redirects %v", path, resp.Redirects)
+		}
+	}
+
+	// a 308 that can't send the body again is returned as a RedirectError
+	_, err = client.Conn.Do("POST", "/permanent", nil, nil, ioutil.NopCloser(strings.NewReader("order")), nil)
+	var redirectErr *RedirectError
+	if !errors.As(err, &redirectErr) || redirectErr.StatusCode != http.StatusPermanentRedirect {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package x_http_client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Request holds the options of one request, set by RequestOptions
+type Request struct {
+	PathParams map[string]string // Values of the {name} parameters of the path template
+	Params     map[string]interface{}
+	Headers    map[string]string
+	Body       io.Reader
+	Listener   ProgressListener
+	Timeout    time.Duration // Time allowed until the response body is closed, no limit when 0
+	Retry      *RetryPolicy  // Retries of failed attempts, none when nil
+}
+
+// RequestOption sets an option of a request
+type RequestOption func(*Request)
+
+// RetryPolicy defines when and how often a failed request is sent again.
+// Unsafe requests are retried only when they carry an Idempotency-Key or were never sent.
+type RetryPolicy struct {
+	MaxRetries int                                  // Retries after the first attempt
+	Backoff    time.Duration                        // Wait before the first retry, doubled on each retry
+	MaxBackoff time.Duration                        // Cap of the wait, no cap when 0
+	RetryOn    func(resp *Response, err error) bool // Failures to retry, transport errors, 429, 502, 503 and 504 by default
+}
+
+// WithPathParam sets the value of the {name} parameter of the path template, it is percent-escaped
+func WithPathParam(name, value string) RequestOption {
+	return func(r *Request) {
+		if r.PathParams == nil {
+			r.PathParams = make(map[string]string)
+		}
+		r.PathParams[name] = value
+	}
+}
+
+// WithQuery adds URL query parameters
+func WithQuery(params map[string]interface{}) RequestOption {
+	return func(r *Request) {
+		if r.Params == nil {
+			r.Params = make(map[string]interface{}, len(params))
+		}
+		for k, v := range params {
+			r.Params[k] = v
+		}
+	}
+}
+
+// WithHeader sets a request header
+func WithHeader(key, value string) RequestOption {
+	return func(r *Request) {
+		if r.Headers == nil {
+			r.Headers = make(map[string]string)
+		}
+		r.Headers[key] = value
+	}
+}
+
+// WithHeaders sets request headers
+func WithHeaders(headers map[string]string) RequestOption {
+	return func(r *Request) {
+		for k, v := range headers {
+			WithHeader(k, v)(r)
+		}
+	}
+}
+
+// WithBody sets the request body
+func WithBody(data io.Reader) RequestOption {
+	return func(r *Request) {
+		r.Body = data
+	}
+}
+
+// WithProgress sets the listener of the upload progress
+func WithProgress(listener ProgressListener) RequestOption {
+	return func(r *Request) {
+		r.Listener = listener
+	}
+}
+
+// WithTimeout bounds the request, including the read of the response body
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(r *Request) {
+		r.Timeout = timeout
+	}
+}
+
+// WithRetryPolicy retries the failed attempts of the request
+func WithRetryPolicy(policy RetryPolicy) RequestOption {
+	return func(r *Request) {
+		r.Retry = &policy
+	}
+}
+
+// WithIdempotencyKey sets the Idempotency-Key of the request, shared by its retries
+func WithIdempotencyKey(key string) RequestOption {
+	return WithHeader(HTTPHeaderIdempotencyKey, key)
+}
+
+// Send sends a request configured by Config.RequestOptions followed by options
+func (conn Conn) Send(ctx context.Context, method, path string, options ...RequestOption) (*Response, error) {
+	r := &Request{}
+	for _, option := range conn.config.RequestOptions {
+		option(r)
+	}
+	for _, option := range options {
+		option(r)
+	}
+
+	if r.PathParams != nil {
+		expanded, err := ExpandPath(path, r.PathParams)
+		if err != nil {
+			return nil, err
+		}
+		path = expanded
+	}
+
+	var cancel context.CancelFunc
+	if r.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+	}
+	req := &request{
+		ctx:       ctx,
+		client:    conn.client,
+		method:    strings.ToUpper(method),
+		path:      path,
+		urlParams: conn.getURLParams(r.Params),
+		data:      r.Body,
+		listener:  r.Listener,
+		retry:     r.Retry,
+	}
+	req.headers, req.idempotencyKey = conn.idempotencyKey(req.method, r.Headers)
+
+	resp, err := conn.send(req)
+	if cancel != nil {
+		// the timeout lasts until the body of a successful response is closed
+		if err == nil && resp != nil && resp.Body != nil {
+			resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+		} else {
+			cancel()
+		}
+	}
+	return resp, err
+}
+
+// sendRetried sends the request again while the policy retries its failures
+func (conn Conn) sendRetried(req *request) (*Response, error) {
+	policy := req.retry
+	rewind := bodyRewinder(req.data)
+	backoff := policy.Backoff
+	for retry := 0; ; retry++ {
+		resp, err := conn.sendOnce(req)
+		if retry >= policy.MaxRetries || req.ctx.Err() != nil || !policy.retries(req, resp, err) || rewind() != nil {
+			return resp, err
+		}
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+		conn.config.WriteLog(Warn, "[Retry]%s %s attempt %d failed:%v, retry in %s\n", req.method, req.path, retry+1, describeFailure(resp, err), backoff)
+
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-req.ctx.Done():
+				timer.Stop()
+				return nil, req.ctx.Err()
+			}
+		}
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// retries reports whether the failed attempt is sent again
+func (policy *RetryPolicy) retries(req *request, resp *Response, err error) bool {
+	if err == nil && (resp == nil || resp.StatusCode < 400) {
+		return false
+	}
+	if !canFailover(req.method, req.idempotencyKey, err) {
+		return false
+	}
+	if policy.RetryOn != nil {
+		return policy.RetryOn(resp, err)
+	}
+	if resp == nil {
+		return err != nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func describeFailure(resp *Response, err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return resp.StatusCode
+}
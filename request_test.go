@@ -0,0 +1,57 @@
+package x_http_client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendOptions(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(500 * time.Millisecond)
+			return
+		}
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(r.Header.Get("X-Tenant") + " " + r.Header.Get("X-Trace") + " " + r.URL.RawQuery + " " + r.Header.Get(HTTPHeaderIdempotencyKey)))
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret",
+		RequestDefaults(WithHeader("X-Tenant", "default"), WithHeader("X-Trace", "on")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Conn.Send(context.Background(), "POST", "/withdraw",
+		WithQuery(map[string]interface{}{"currency": "USD"}),
+		WithHeader("X-Tenant", "acme"),
+		WithBody(strings.NewReader("{}")),
+		WithIdempotencyKey("withdraw-1"),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if body := resp.GetBodyText(); body != "acme on currency=USD withdraw-1" || attempts != 3 {
+		t.Fatalf("unexpected response %q after %d attempts", body, attempts)
+	}
+
+	_, err = client.Conn.Send(context.Background(), "GET", "/slow", WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	if _, err := New(server.URL, "id", "secret", RequestDefaults(WithBody(strings.NewReader("{}")))); err == nil {
+		t.Fatal("expected an error for a default body")
+	}
+}
@@ -0,0 +1,244 @@
+package x_http_client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is one server-sent event of a text/event-stream response
+type Event struct {
+	ID    string // Last event ID, carried over from previous events when the event has no id field
+	Event string // Event type, "message" by default
+	Data  string // Data lines joined with "\n"
+}
+
+// EventStream reads server-sent events and reconnects with Last-Event-ID when the connection drops
+type EventStream struct {
+	conn   *Conn
+	path   string
+	params map[string]interface{}
+
+	events chan *Event
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	lastEventID string
+	retry       time.Duration
+	err         error
+
+	closeOnce sync.Once
+}
+
+// Stream opens a server-sent events stream on path. The first connection is made before returning,
+// later ones are made in the background until ctx is done, Close is called or reconnecting fails.
+func (client *Client) Stream(ctx context.Context, path string, params map[string]interface{}) (*EventStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream := &EventStream{
+		conn:   client.Conn,
+		path:   path,
+		params: params,
+		events: make(chan *Event),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		retry:  client.Config.StreamRetryInterval,
+	}
+
+	resp, err := stream.connect()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go stream.run(resp)
+	return stream, nil
+}
+
+// Events returns the channel of received events, closed when the stream ends
+func (s *EventStream) Events() <-chan *Event {
+	return s.events
+}
+
+// Err returns the reason the stream ended, valid once Events is closed
+func (s *EventStream) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close stops the stream and waits for the reading goroutine to exit
+func (s *EventStream) Close() error {
+	s.closeOnce.Do(s.cancel)
+	<-s.done
+	return nil
+}
+
+// connect sends the signed stream request
+func (s *EventStream) connect() (*Response, error) {
+	headers := map[string]string{
+		HTTPHeaderAccept:       "text/event-stream",
+		HTTPHeaderCacheControl: "no-cache",
+	}
+	if s.lastEventID != "" {
+		headers[HTTPHeaderLastEventID] = s.lastEventID
+	}
+
+	resp, err := s.conn.sendLimited(&request{
+		ctx:       s.ctx,
+		client:    s.conn.streamClient,
+		method:    string(HTTPGet),
+		path:      s.path,
+		urlParams: s.conn.getURLParams(s.params),
+		headers:   headers,
+	})
+	if err != nil {
+		if resp != nil {
+			resp.Close()
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		// the server asks not to reconnect
+		resp.Close()
+		return nil, io.EOF
+	}
+	if ct := mediaType(resp.Headers.Get(HTTPHeaderContentType)); ct != "text/event-stream" {
+		resp.Close()
+		return nil, fmt.Errorf("stream returned Content-Type %q, want text/event-stream", ct)
+	}
+	return resp, nil
+}
+
+func (s *EventStream) run(resp *Response) {
+	defer close(s.done)
+	defer close(s.events)
+
+	maxReconnects := s.conn.config.StreamMaxReconnects
+	failures := 0
+	for {
+		received, err := s.read(resp)
+		resp.Close()
+		if s.ctx.Err() != nil {
+			s.err = s.ctx.Err()
+			return
+		}
+		if received {
+			failures = 0
+		}
+		s.conn.config.WriteLog(Info, "[Stream:%s]disconnected:%v, reconnect in %s\n", s.path, err, s.retry)
+
+		for {
+			if maxReconnects > 0 && failures >= maxReconnects {
+				s.err = fmt.Errorf("stream reconnect failed %d times: %v", failures, err)
+				return
+			}
+			failures++
+
+			select {
+			case <-s.ctx.Done():
+				s.err = s.ctx.Err()
+				return
+			case <-time.After(s.retry):
+			}
+
+			resp, err = s.connect()
+			if err == nil {
+				break
+			}
+			if err == io.EOF || isPermanentStreamError(err) {
+				s.err = err
+				return
+			}
+		}
+	}
+}
+
+// isPermanentStreamError reports whether reconnecting can't fix err
+func isPermanentStreamError(err error) bool {
+	var srvErr *ServiceError
+	if !errors.As(err, &srvErr) {
+		return false
+	}
+	return srvErr.StatusCode >= 400 && srvErr.StatusCode < 500 &&
+		srvErr.StatusCode != http.StatusRequestTimeout && srvErr.StatusCode != http.StatusTooManyRequests
+}
+
+// read parses events from resp until the body ends, it reports whether any event was received
+func (s *EventStream) read(resp *Response) (bool, error) {
+	reader := bufio.NewReader(resp.Body)
+	received := false
+	var eventType string
+	var data strings.Builder
+	hasData := false
+	// the id becomes the last event ID only once its event is dispatched
+	pendingID := s.lastEventID
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return received, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			// dispatch
+			s.lastEventID = pendingID
+			if hasData {
+				event := &Event{ID: s.lastEventID, Event: eventType, Data: data.String()}
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				select {
+				case s.events <- event:
+					received = true
+				case <-s.ctx.Done():
+					return received, s.ctx.Err()
+				}
+			}
+			eventType = ""
+			data.Reset()
+			hasData = false
+			continue
+		}
+		if line[0] == ':' {
+			// comment, used as keep-alive
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				pendingID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil && ms >= 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package x_http_client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamReconnectsWithLastEventID(t *testing.T) {
+	lastEventIDs := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HTTPHeaderAuthorization) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		lastEventIDs <- r.Header.Get(HTTPHeaderLastEventID)
+		w.Header().Set(HTTPHeaderContentType, "text/event-stream")
+		if r.Header.Get(HTTPHeaderLastEventID) == "" {
+			fmt.Fprint(w, "retry: 10\n: keep-alive\n\nid: 1\nevent: trade\ndata: {\"a\":1}\ndata: {\"b\":2}\n\n")
+			return
+		}
+		fmt.Fprint(w, "id: 2\ndata: second\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Stream(ctx, "/notify", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := <-stream.Events()
+	if first.ID != "1" || first.Event != "trade" || first.Data != "{\"a\":1}\n{\"b\":2}" {
+		t.Fatalf("unexpected first event %+v", first)
+	}
+	second := <-stream.Events()
+	if second.ID != "2" || second.Event != "message" || second.Data != "second" {
+		t.Fatalf("unexpected second event %+v", second)
+	}
+	if id := <-lastEventIDs; id != "" {
+		t.Fatalf("first connection sent Last-Event-ID %q", id)
+	}
+	if id := <-lastEventIDs; id != "1" {
+		t.Fatalf("reconnection sent Last-Event-ID %q, want 1", id)
+	}
+
+	stream.Close()
+	if _, ok := <-stream.Events(); ok {
+		t.Fatal("events channel still open after Close")
+	}
+	if stream.Err() != context.Canceled {
+		t.Fatalf("Err = %v", stream.Err())
+	}
+}
+
+func TestStreamKeepsUndispatchedEventID(t *testing.T) {
+	lastEventIDs := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastEventIDs <- r.Header.Get(HTTPHeaderLastEventID)
+		w.Header().Set(HTTPHeaderContentType, "text/event-stream")
+		if r.Header.Get(HTTPHeaderLastEventID) == "" {
+			// the connection drops before event 2 is complete
+			fmt.Fprint(w, "retry: 10\nid: 1\ndata: first\n\nid: 2\n")
+			return
+		}
+		fmt.Fprint(w, "id: 2\ndata: second\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Stream(ctx, "/notify", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	for _, want := range []string{"first", "second"} {
+		if event := <-stream.Events(); event == nil || event.Data != want {
+			t.Fatalf("unexpected event %+v, want %s", event, want)
+		}
+	}
+	<-lastEventIDs
+	if id := <-lastEventIDs; id != "1" {
+		t.Fatalf("reconnection sent Last-Event-ID %q, want 1", id)
+	}
+}
@@ -0,0 +1,176 @@
+package x_http_client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrPinMismatch is matched by errors.Is when no certificate of the server chain matches TLSConfig.PinnedSPKI
+var ErrPinMismatch = errors.New("certificate pin mismatch")
+
+// PinMismatchError reports the SPKI pins presented by a server that matched none of TLSConfig.PinnedSPKI
+type PinMismatchError struct {
+	ServerName string
+	Pins       []string // base64 SHA-256 pins of the verified chain
+}
+
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf("certificate pin mismatch: ServerName=%s, Pins=%v", e.ServerName, e.Pins)
+}
+
+func (e *PinMismatchError) Is(target error) bool {
+	return target == ErrPinMismatch
+}
+
+// TLSConfig defines the TLS settings of the connections to the endpoints
+type TLSConfig struct {
+	RootCAFiles []string // PEM files of the trusted CAs, the system roots are used when no CA is given
+	RootCAPEM   []byte   // PEM encoded trusted CAs
+
+	ClientCertFile string // PEM client certificate for mutual TLS, reloaded when the files change
+	ClientKeyFile  string // PEM key of ClientCertFile
+	ClientCertPEM  []byte // PEM client certificate, used when ClientCertFile is empty
+	ClientKeyPEM   []byte // PEM key of ClientCertPEM
+
+	MinVersion   uint16   // Minimum TLS version, tls.VersionTLS12 by default
+	CipherSuites []uint16 // Cipher suites of TLS 1.2 and below, Go defaults when empty
+	ServerName   string   // SNI and verified host name, the endpoint host by default
+
+	PinnedSPKI []string // base64 SHA-256 of the SubjectPublicKeyInfo of any certificate of the verified chain
+}
+
+// newTLSConfig builds the crypto/tls configuration of config
+func newTLSConfig(config *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:   config.MinVersion,
+		CipherSuites: config.CipherSuites,
+		ServerName:   config.ServerName,
+	}
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+
+	if len(config.RootCAFiles) > 0 || len(config.RootCAPEM) > 0 {
+		pool := x509.NewCertPool()
+		for _, file := range config.RootCAFiles {
+			pem, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no CA certificate found in %s", file)
+			}
+		}
+		if len(config.RootCAPEM) > 0 && !pool.AppendCertsFromPEM(config.RootCAPEM) {
+			return nil, errors.New("no CA certificate found in RootCAPEM")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCertFile != "" {
+		reloader := &certReloader{certFile: config.ClientCertFile, keyFile: config.ClientKeyFile}
+		if _, err := reloader.certificate(); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		}
+	} else if len(config.ClientCertPEM) > 0 {
+		cert, err := tls.X509KeyPair(config.ClientCertPEM, config.ClientKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(config.PinnedSPKI) > 0 {
+		pins := make(map[string]bool, len(config.PinnedSPKI))
+		for _, pin := range config.PinnedSPKI {
+			pins[pin] = true
+		}
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyPins(state, pins)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// verifyPins checks the verified chains against the pins, the chains are empty when verification is skipped
+func verifyPins(state tls.ConnectionState, pins map[string]bool) error {
+	chains := state.VerifiedChains
+	if len(chains) == 0 {
+		chains = [][]*x509.Certificate{state.PeerCertificates}
+	}
+	var seen []string
+	for _, chain := range chains {
+		for _, cert := range chain {
+			pin := SPKIPin(cert)
+			if pins[pin] {
+				return nil
+			}
+			seen = append(seen, pin)
+		}
+	}
+	return &PinMismatchError{ServerName: state.ServerName, Pins: seen}
+}
+
+// SPKIPin returns the base64 SHA-256 of the SubjectPublicKeyInfo of cert, the format of TLSConfig.PinnedSPKI
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// certReloader loads the client certificate again when its files are modified
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	lock    sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.cert != nil && modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// the files may be half written, keep the previous pair
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	r.cert, r.modTime = &cert, modTime
+	return r.cert, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,96 @@
+package x_http_client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLSPinning(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	client, err := New(server.URL, "id", "secret",
+		TLS(TLSConfig{RootCAPEM: caPEM, PinnedSPKI: []string{SPKIPin(server.Certificate())}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Conn.Do("GET", "/", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+
+	client, err = New(server.URL, "id", "secret",
+		TLS(TLSConfig{RootCAPEM: caPEM, PinnedSPKI: []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Conn.Do("GET", "/", nil, nil, nil, nil)
+	var pinErr *PinMismatchError
+	if !errors.Is(err, ErrPinMismatch) || !errors.As(err, &pinErr) || pinErr.Pins[0] != SPKIPin(server.Certificate()) {
+		t.Fatalf("expected a pin mismatch, got %v", err)
+	}
+}
+
+func TestClientCertReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeTestCert(t, certFile, keyFile, "client-1", time.Now().Add(-time.Minute))
+
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	first, err := reloader.certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestCert(t, certFile, keyFile, "client-2", time.Now())
+	second, err := reloader.certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || second.Leaf.Subject.CommonName != "client-2" {
+		t.Fatalf("certificate not reloaded: %s", second.Leaf.Subject.CommonName)
+	}
+}
+
+func writeTestCert(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	os.Chtimes(certFile, modTime, modTime)
+	os.Chtimes(keyFile, modTime, modTime)
+}
@@ -0,0 +1,70 @@
+package x_http_client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// unixHostSuffix marks the synthetic hosts standing for Unix domain sockets in endpoint URLs
+const unixHostSuffix = ".unix.localhost"
+
+// isUnixEndpoint reports whether endpoint dials a Unix domain socket
+func isUnixEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "unix://") || strings.HasPrefix(endpoint, "http+unix://")
+}
+
+// parseUnixEndpoint parses unix:///var/run/agent.sock, or http+unix://%2Fvar%2Frun%2Fagent.sock/v1 whose
+// percent-encoded host is the socket and path the base path of every request, as Docker clients do.
+// It returns the socket, the synthetic host the dialer routes to it and the escaped base path.
+func parseUnixEndpoint(endpoint string) (socket, host, prefix string, err error) {
+	if strings.HasPrefix(endpoint, "unix://") {
+		socket = strings.TrimPrefix(endpoint, "unix://")
+	} else {
+		escaped, path := strings.TrimPrefix(endpoint, "http+unix://"), ""
+		if i := strings.IndexByte(escaped, '/'); i >= 0 {
+			escaped, path = escaped[:i], escaped[i:]
+		}
+		if socket, err = url.PathUnescape(escaped); err != nil {
+			return "", "", "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+		}
+		// the base path is escaped like the base path of http endpoints
+		base, err := url.Parse("http://localhost" + path)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+		}
+		if base.RawQuery != "" || base.Fragment != "" {
+			return "", "", "", fmt.Errorf("invalid endpoint %q, query and fragment are not allowed", endpoint)
+		}
+		prefix = strings.TrimRight(base.EscapedPath(), "/")
+	}
+	if !strings.HasPrefix(socket, "/") {
+		return "", "", "", fmt.Errorf("invalid endpoint %q, socket path must be absolute", endpoint)
+	}
+
+	// the host keeps the connections of every socket apart in the transport pool
+	sum := sha256.Sum256([]byte(socket))
+	host = hex.EncodeToString(sum[:8]) + unixHostSuffix
+	return socket, host, prefix, nil
+}
+
+// isUnixHost reports whether host, which may carry a port, is the synthetic host of a unix:// endpoint
+func isUnixHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.HasSuffix(host, unixHostSuffix)
+}
+
+// unixSocket returns the socket of the synthetic host of a unix:// endpoint, addr may carry a port
+func (r *hostResolver) unixSocket(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	socket, ok := r.sockets[host]
+	return socket, ok
+}
@@ -0,0 +1,61 @@
+package x_http_client
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnixEndpoint(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HTTPHeaderAuthorization) == "" {
+			t.Error("request not signed")
+		}
+		w.Write([]byte(r.Host + " " + r.URL.EscapedPath()))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client, err := New("http+unix://"+url.PathEscape(socket)+"/v1/", "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Conn.Do("POST", "/sign", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if body := resp.GetBodyText(); body != "localhost /v1/sign" {
+		t.Fatalf("unexpected request %q", body)
+	}
+
+	// the socket of the endpoint wins over Config.UnixSocket, the base path is escaped like http ones
+	client, err = New("unix://"+socket, "id", "secret", UnixSocket(filepath.Join(t.TempDir(), "none.sock")),
+		Endpoints(Endpoint{URL: "http+unix://" + url.PathEscape(socket) + "/api v1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Conn.Do("GET", "/sign", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	if body := resp.GetBodyText(); body != "localhost /api%20v1/sign" {
+		t.Fatalf("unexpected request %q", body)
+	}
+
+	for _, endpoint := range []string{"unix://agent.sock", "http+unix://agent.sock/v1", "http+unix://%2Fagent.sock/v1?x=1"} {
+		if _, err := New(endpoint, "id", "secret"); err == nil {
+			t.Fatalf("expected an error for %q", endpoint)
+		}
+	}
+}
@@ -11,18 +11,22 @@ import (
 const (
 	urlTypeIP     = 2
 	urlTypeAliyun = 3
+	urlTypeUnix   = 4
 )
 
 type urlMaker struct {
-	Scheme string // HTTP or HTTPS
-	NetLoc string // Host or IP
-	Type   int    //  2 IP, 3 ALIYUN
+	Scheme     string // HTTP or HTTPS
+	NetLoc     string // Host or IP
+	Type       int    //  2 IP, 3 ALIYUN, 4 Unix domain socket
+	PathPrefix string // Prepended to the request paths
+	Socket     string // Unix domain socket dialed for NetLoc, unix:// endpoints only
 	// IsProxy bool   // Proxy
 }
 
-// getURL gets URL
-func (um urlMaker) getURL(path, params string) *url.URL {
+// getURL gets URL, path is expected to be escaped already
+func (um urlMaker) getURL(path, params string) (*url.URL, error) {
 	host := um.NetLoc
+	path = um.PathPrefix + path
 
 	addr := ""
 	if params == "" {
@@ -30,11 +34,27 @@ func (um urlMaker) getURL(path, params string) *url.URL {
 	} else {
 		addr = fmt.Sprintf("%s://%s%s?%s", um.Scheme, host, path, params)
 	}
-	uri, _ := url.ParseRequestURI(addr)
-	return uri
+	uri, err := url.ParseRequestURI(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URL: %w", err)
+	}
+	return uri, nil
 }
 
 func (um *urlMaker) Init(endpoint string) error {
+	if isUnixEndpoint(endpoint) {
+		socket, host, prefix, err := parseUnixEndpoint(endpoint)
+		if err != nil {
+			return err
+		}
+		um.Scheme = "http"
+		um.NetLoc = host
+		um.PathPrefix = prefix
+		um.Socket = socket
+		um.Type = urlTypeUnix
+		return nil
+	}
+
 	if strings.HasPrefix(endpoint, "http://") {
 		um.Scheme = "http"
 		um.NetLoc = endpoint[len("http://"):]
@@ -54,6 +74,14 @@ func (um *urlMaker) Init(endpoint string) error {
 	}
 
 	um.NetLoc = url.Host
+	if um.NetLoc == "" {
+		return fmt.Errorf("invalid endpoint %q, host is empty", endpoint)
+	}
+	// the endpoint path is the base path of every request
+	um.PathPrefix = strings.TrimRight(url.EscapedPath(), "/")
+	if url.RawQuery != "" || url.Fragment != "" {
+		return fmt.Errorf("invalid endpoint %q, query and fragment are not allowed", endpoint)
+	}
 	host, _, err := net.SplitHostPort(um.NetLoc)
 	if err != nil {
 		host = um.NetLoc
@@ -81,25 +109,34 @@ type timeoutConn struct {
 }
 
 func newTimeoutConn(conn net.Conn, timeout time.Duration, longTimeout time.Duration) *timeoutConn {
-	conn.SetReadDeadline(time.Now().Add(longTimeout))
-	return &timeoutConn{
+	c := &timeoutConn{
 		conn:        conn,
 		timeout:     timeout,
 		longTimeout: longTimeout,
 	}
+	c.SetReadDeadline(c.deadline(longTimeout))
+	return c
+}
+
+// deadline returns now plus d, or no deadline when d is zero
+func (c *timeoutConn) deadline(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
 }
 
 func (c *timeoutConn) Read(b []byte) (n int, err error) {
-	c.SetReadDeadline(time.Now().Add(c.timeout))
+	c.SetReadDeadline(c.deadline(c.timeout))
 	n, err = c.conn.Read(b)
-	c.SetReadDeadline(time.Now().Add(c.longTimeout))
+	c.SetReadDeadline(c.deadline(c.longTimeout))
 	return n, err
 }
 
 func (c *timeoutConn) Write(b []byte) (n int, err error) {
-	c.SetWriteDeadline(time.Now().Add(c.timeout))
+	c.SetWriteDeadline(c.deadline(c.timeout))
 	n, err = c.conn.Write(b)
-	c.SetReadDeadline(time.Now().Add(c.longTimeout))
+	c.SetReadDeadline(c.deadline(c.longTimeout))
 	return n, err
 }
 
@@ -150,6 +187,7 @@ type ProgressEvent struct {
 	ConsumedBytes int64
 	TotalBytes    int64
 	RwBytes       int64
+	ContentBytes  int64 // Uncompressed size of the request body, TotalBytes is the size sent when it is gzip compressed
 	EventType     ProgressEventType
 }
 
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"crypto/tls"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -42,29 +43,54 @@ func newTransport(conn *Conn, config *Config) *http.Transport {
 	httpMaxConns := conn.config.HTTPMaxConns
 	// New Transport
 	transport := &http.Transport{
-		DialContext: func(ctx context.Context, netw, addr string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout:   httpTimeOut.ConnectTimeout,
-				KeepAlive: 30 * time.Second,
-			}
-			if config.LocalAddr != nil {
-				d.LocalAddr = config.LocalAddr
-			}
-			conn, err := d.DialContext(ctx, netw, addr)
-			if err != nil {
-				return nil, err
-			}
-			return newTimeoutConn(conn, httpTimeOut.ReadWriteTimeout, httpTimeOut.LongTimeout), nil
-		},
+		DialContext:           newDialContext(config, conn.resolver, httpTimeOut.ReadWriteTimeout, httpTimeOut.LongTimeout),
 		MaxIdleConns:          httpMaxConns.MaxIdleConns,
 		MaxIdleConnsPerHost:   httpMaxConns.MaxIdleConnsPerHost,
 		IdleConnTimeout:       httpTimeOut.IdleConnTimeout,
 		ResponseHeaderTimeout: httpTimeOut.HeaderTimeout,
+		DisableCompression:    true, // Config.AcceptEncodings handles compression
+	}
+	if config.HTTP2 != nil {
+		configureHTTP2(transport, config.HTTP2)
+		if config.HTTP2.Cleartext {
+			// deadlines on a multiplexed connection would fail every stream, response bodies get per-read timeouts instead
+			transport.DialContext = newDialContext(config, conn.resolver, 0, 0)
+		} else {
+			// h2 is only negotiated over TLS, cleartext and HTTP/1.1 connections keep their deadlines
+			transport.DialTLSContext = newDialTLSContext(config, conn.resolver, func() *tls.Config { return transport.TLSClientConfig },
+				httpTimeOut.ReadWriteTimeout, httpTimeOut.LongTimeout)
+		}
 	}
 	return transport
 }
 
-
+// newDialContext returns a dial function wrapping connections in timeoutConn, zero timeouts disable the deadlines.
+// Hosts are resolved by resolver, or every connection goes to Config.UnixSocket when set.
+// The hosts of unix:// endpoints always dial their socket.
+func newDialContext(config *Config, resolver *hostResolver, timeout, longTimeout time.Duration) func(ctx context.Context, netw, addr string) (net.Conn, error) {
+	return func(ctx context.Context, netw, addr string) (net.Conn, error) {
+		d := net.Dialer{
+			Timeout:   config.HTTPTimeout.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		var conn net.Conn
+		var err error
+		if socket, ok := resolver.unixSocket(addr); ok {
+			conn, err = d.DialContext(ctx, "unix", socket)
+		} else if config.UnixSocket != "" {
+			conn, err = d.DialContext(ctx, "unix", config.UnixSocket)
+		} else {
+			if config.LocalAddr != nil {
+				d.LocalAddr = config.LocalAddr
+			}
+			conn, err = resolver.dial(ctx, &d, netw, addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return newTimeoutConn(conn, timeout, longTimeout), nil
+	}
+}
 
 func calcMD5(body io.Reader, contentLen, md5Threshold int64) (reader io.Reader, b64 string, tempFile *os.File, err error) {
 	if contentLen == 0 || contentLen > md5Threshold {
@@ -90,7 +116,6 @@ func calcMD5(body io.Reader, contentLen, md5Threshold int64) (reader io.Reader,
 	return
 }
 
-
 // userAgent gets user agent
 // It has the SDK version information, OS information and GO version
 func userAgent() string {
@@ -111,9 +136,6 @@ func getSysInfo() sysInfo {
 	return sysInfo{name: sys_name, release: sys_release, machine: sys_machine}
 }
 
-
-
-
 func GetReaderLen(reader io.Reader) (int64, error) {
 	var contentLength int64
 	var err error
@@ -158,4 +180,4 @@ func (lc *LimitedReadCloser) Close() error {
 		return closer.Close()
 	}
 	return nil
-}
\ No newline at end of file
+}
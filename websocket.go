@@ -0,0 +1,438 @@
+package x_http_client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket message types
+const (
+	WebSocketTextMessage   = 1
+	WebSocketBinaryMessage = 2
+)
+
+// WebSocket frame opcodes, RFC 6455 section 5.2
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsAcceptGUID is appended to Sec-WebSocket-Key to compute Sec-WebSocket-Accept
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket close codes sent by the client, RFC 6455 section 7.4.1
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseMessageTooBig = 1009
+)
+
+// webSocketFailure fails the connection with a close code
+type webSocketFailure struct {
+	code int
+	text string
+}
+
+func (f *webSocketFailure) Error() string {
+	return f.text
+}
+
+// WebSocketCloseError is the close frame received from the server, or the one the client sent when the server
+// broke the protocol (1002) or sent a message larger than Config.WebSocketMaxMessageSize (1009)
+type WebSocketCloseError struct {
+	Code int    // Close status code, 1005 when the frame had none
+	Text string // Close reason
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("websocket closed: Code=%d, Text=%q", e.Code, e.Text)
+}
+
+// WebSocketConn is a signed WebSocket connection kept alive with ping/pong.
+// Reads must come from one goroutine, writes are serialized internally.
+type WebSocketConn struct {
+	Response *http.Response // Handshake response
+
+	conn      net.Conn           // dialed connection, used for deadlines
+	rw        io.ReadWriteCloser // upgraded stream, which may be TLS on top of conn
+	reader    *bufio.Reader
+	config    *Config
+	writeLock sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// DialWebSocket opens a WebSocket on path of the client endpoint. The upgrade request is signed like
+// every other request and sent through the client transport over HTTP/1.1, so proxies, TLS and the
+// dialing settings apply. http and https endpoints map to ws and wss.
+func (client *Client) DialWebSocket(ctx context.Context, path string, params map[string]interface{}, headers map[string]string) (*WebSocketConn, error) {
+	conn := client.Conn
+	config := conn.config
+
+	ep := conn.endpoints.pick(nil)
+	uri, err := ep.url.getURL(path, conn.getURLParams(params))
+	if err != nil {
+		return nil, err
+	}
+	req := &http.Request{
+		Method: string(HTTPGet),
+		URL:    uri,
+		Header: make(http.Header),
+		Host:   uri.Host,
+	}
+	if isUnixHost(uri.Host) {
+		req.Host = "localhost"
+	}
+	req.Header.Set(HTTPHeaderDate, ep.now().UTC().Format(http.TimeFormat))
+	req.Header.Set(HTTPHeaderUserAgent, config.UserAgent)
+	akIf, err := config.LoadCredentials()
+	if err != nil {
+		return nil, &SignatureError{Method: req.Method, URL: uri.String(), Err: err}
+	}
+	if akIf.GetSecurityToken() != "" {
+		req.Header.Set(HTTPHeaderSecurityToken, akIf.GetSecurityToken())
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	key, err := newWebSocketKey()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	conn.signHeader(req, akIf)
+
+	wsClient, dialed := conn.webSocketClient()
+	if timeout := config.HTTPTimeout.HeaderTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req = req.WithContext(ctx)
+
+	if config.LogLevel >= Debug {
+		conn.LoggerHTTPReq(req)
+	}
+	resp, err := wsClient.Do(req)
+	conn.endpoints.report(ep, err != nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		if _, srvErr := conn.handleResponse(resp, conn.errorDecoder(ep)); srvErr != nil {
+			return nil, srvErr
+		}
+		resp.Body.Close()
+		return nil, fmt.Errorf("websocket handshake returned status %d, want 101", resp.StatusCode)
+	}
+
+	rw, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok || !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		resp.Body.Close()
+		return nil, errors.New("websocket handshake returned an invalid upgrade response")
+	}
+
+	wsConn := &WebSocketConn{
+		Response: resp,
+		conn:     *dialed,
+		rw:       rw,
+		reader:   bufio.NewReader(rw),
+		config:   config,
+		done:     make(chan struct{}),
+	}
+	wsConn.keepAlive()
+	return wsConn, nil
+}
+
+// webSocketClient returns an HTTP/1.1 client for one upgrade, dialed without the timeoutConn deadlines
+// which ping/pong replaces. The dialed connection is stored in the returned pointer.
+func (conn Conn) webSocketClient() (*http.Client, *net.Conn) {
+	dialed := new(net.Conn)
+	transport, ok := conn.client.Transport.(*http.Transport)
+	if !ok {
+		return conn.client, dialed
+	}
+
+	transport = transport.Clone()
+	dial := newDialContext(conn.config, conn.resolver, 0, 0)
+	transport.DialContext = func(ctx context.Context, netw, addr string) (net.Conn, error) {
+		c, err := dial(ctx, netw, addr)
+		if tc, ok := c.(*timeoutConn); ok {
+			c = tc.conn
+		}
+		if err == nil {
+			*dialed = c
+		}
+		return c, err
+	}
+	// WebSocket handshakes need HTTP/1.1, the transport may also offer h2
+	transport.DialTLSContext = nil
+	transport.Protocols = new(http.Protocols)
+	transport.Protocols.SetHTTP1(true)
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, dialed
+}
+
+// newWebSocketKey returns a random Sec-WebSocket-Key
+func newWebSocketKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// webSocketAccept returns the Sec-WebSocket-Accept expected for key
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// keepAlive pings the server until Close, it's disabled when WebSocketPingInterval is not positive
+func (c *WebSocketConn) keepAlive() {
+	c.extendReadDeadline()
+
+	interval := c.config.WebSocketPingInterval
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				if err := c.writeFrame(wsPing, nil); err != nil {
+					c.config.WriteLog(Warn, "[WebSocket:%p]ping error:%s\n", c, err.Error())
+					return
+				}
+			}
+		}
+	}()
+}
+
+// extendReadDeadline allows WebSocketPongWait for the next frame, there is no deadline when it's not positive
+func (c *WebSocketConn) extendReadDeadline() {
+	if c.conn == nil {
+		return
+	}
+	if pongWait := c.config.WebSocketPongWait; pongWait > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	}
+}
+
+// ReadMessage reads the next text or binary message, answering pings and close frames on the way.
+// The connection is closed when the server breaks the protocol or the message is too large.
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	messageType, data, err = c.readMessage()
+	if failure, ok := err.(*webSocketFailure); ok {
+		c.closeWith(failure.code, failure.text)
+		return -1, nil, &WebSocketCloseError{Code: failure.code, Text: failure.text}
+	}
+	return messageType, data, err
+}
+
+func (c *WebSocketConn) readMessage() (messageType int, data []byte, err error) {
+	var message []byte
+	messageType = -1
+	for {
+		remaining := int64(-1)
+		if max := c.config.WebSocketMaxMessageSize; max > 0 {
+			remaining = max - int64(len(message))
+		}
+		fin, opcode, payload, err := readWebSocketFrame(c.reader, remaining)
+		if err != nil {
+			return -1, nil, err
+		}
+		c.extendReadDeadline()
+
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return -1, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			closeErr := &WebSocketCloseError{Code: 1005}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Text = string(payload[2:])
+			}
+			c.writeFrame(wsClose, payload[:min(len(payload), 2)])
+			return -1, nil, closeErr
+		case wsText, wsBinary:
+			if messageType != -1 {
+				return -1, nil, &webSocketFailure{wsCloseProtocolError, "message interrupted by a new message"}
+			}
+			messageType = int(opcode)
+		case wsContinuation:
+			if messageType == -1 {
+				return -1, nil, &webSocketFailure{wsCloseProtocolError, "continuation frame without a message"}
+			}
+		default:
+			return -1, nil, &webSocketFailure{wsCloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode)}
+		}
+
+		message = append(message, payload...)
+		if fin {
+			return messageType, message, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v
+func (c *WebSocketConn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage writes a text or binary message
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != WebSocketTextMessage && messageType != WebSocketBinaryMessage {
+		return fmt.Errorf("websocket message type %d is not text or binary", messageType)
+	}
+	return c.writeFrame(byte(messageType), data)
+}
+
+// WriteJSON writes v encoded as JSON in a text message
+func (c *WebSocketConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsText, data)
+}
+
+// Close sends a close frame and closes the connection
+func (c *WebSocketConn) Close() error {
+	return c.closeWith(wsCloseNormal, "")
+}
+
+// closeWith sends a close frame with code and text and closes the connection
+func (c *WebSocketConn) closeWith(code int, text string) error {
+	err := error(nil)
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.writeFrame(wsClose, append(binary.BigEndian.AppendUint16(nil, uint16(code)), text...))
+		err = c.rw.Close()
+	})
+	return err
+}
+
+// writeFrame writes one masked frame within WebSocketWriteTimeout
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.conn != nil && c.config.WebSocketWriteTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.config.WebSocketWriteTimeout))
+	}
+	return writeWebSocketFrame(c.rw, opcode, payload)
+}
+
+// writeWebSocketFrame writes a final masked frame, clients must mask their frames
+func writeWebSocketFrame(w io.Writer, opcode byte, payload []byte) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	header[1] |= 0x80
+	var key [4]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return err
+	}
+	header = append(header, key[:]...)
+	data := make([]byte, len(payload))
+	for i := range payload {
+		data[i] = payload[i] ^ key[i%4]
+	}
+	_, err := w.Write(append(header, data...))
+	return err
+}
+
+// readWebSocketFrame reads one server frame, a data frame longer than max fails before its payload
+// is allocated, there is no limit when max is negative
+func readWebSocketFrame(r *bufio.Reader, max int64) (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(r, head[:]); err != nil {
+		return
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0F
+	if head[0]&0x70 != 0 {
+		return fin, opcode, nil, &webSocketFailure{wsCloseProtocolError, "reserved bits set"}
+	}
+	if head[1]&0x80 != 0 {
+		// servers must not mask their frames
+		return fin, opcode, nil, &webSocketFailure{wsCloseProtocolError, "masked server frame"}
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsClose && (length > 125 || !fin) {
+		return fin, opcode, nil, &webSocketFailure{wsCloseProtocolError, "control frame fragmented or too long"}
+	}
+	if opcode < wsClose && ((max >= 0 && length > uint64(max)) || length > 1<<31) {
+		return fin, opcode, nil, &webSocketFailure{wsCloseMessageTooBig, "message too big"}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	return fin, opcode, payload, nil
+}
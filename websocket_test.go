@@ -0,0 +1,305 @@
+package x_http_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serverFrame encodes an unmasked server frame, apart from the client frame code so both can't share a bug
+func serverFrame(fin bool, opcode byte, payload []byte) []byte {
+	var frame []byte
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = []byte{first, byte(n)}
+	case n < 1<<16:
+		frame = []byte{first, 126, byte(n >> 8), byte(n)}
+	default:
+		frame = []byte{first, 127, 0, 0, 0, 0, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	return append(frame, payload...)
+}
+
+// readClientFrame decodes one client frame, which must be masked
+func readClientFrame(r *bufio.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	head := make([]byte, 2)
+	if _, err = io.ReadFull(r, head); err != nil {
+		return
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("unmasked client frame")
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0F
+	n := int(head[1] & 0x7F)
+	if n >= 126 {
+		ext := make([]byte, map[int]int{126: 2, 127: 8}[n])
+		if _, err = io.ReadFull(r, ext); err != nil {
+			return
+		}
+		n = 0
+		for _, b := range ext {
+			n = n<<8 | int(b)
+		}
+	}
+	key := make([]byte, 4)
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(r, key); err != nil {
+		return
+	}
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= key[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// webSocketHandler upgrades signed requests and serves the connection with serve
+func webSocketHandler(t *testing.T, serve func(r *bufio.Reader, w *bufio.Writer)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get(HTTPHeaderAuthorization), "KT id:") {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":401,"msg":"bad signature"}`))
+			return
+		}
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		netConn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer netConn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+		serve(rw.Reader, rw.Writer)
+	}
+}
+
+func TestDialWebSocket(t *testing.T) {
+	pings := make(chan struct{}, 1)
+	server := httptest.NewServer(webSocketHandler(t, func(r *bufio.Reader, w *bufio.Writer) {
+		var message []byte
+		for {
+			fin, opcode, payload, err := readClientFrame(r)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			switch opcode {
+			case 0x9:
+				select {
+				case pings <- struct{}{}:
+				default:
+				}
+				w.Write(serverFrame(true, 0xA, payload))
+			case 0x8:
+				w.Write(serverFrame(true, 0x8, payload))
+				w.Flush()
+				return
+			default:
+				message = append(message, payload...)
+				if fin {
+					w.Write(serverFrame(true, 0x1, append([]byte("echo:"), message...)))
+					message = nil
+				}
+			}
+			w.Flush()
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Config.WebSocketPingInterval = 10 * time.Millisecond
+
+	ws, err := client.DialWebSocket(context.Background(), "/feed", map[string]interface{}{"symbol": "USD"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if err := ws.WriteMessage(WebSocketTextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	messageType, data, err := ws.ReadMessage()
+	if err != nil || messageType != WebSocketTextMessage || string(data) != "echo:hi" {
+		t.Fatalf("ReadMessage = %d %q, %v", messageType, data, err)
+	}
+
+	big := strings.Repeat("x", 70000)
+	if err := ws.WriteMessage(WebSocketBinaryMessage, []byte(big)); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := ws.ReadMessage(); err != nil || string(data) != "echo:"+big {
+		t.Fatalf("large message echoed as %d bytes, %v", len(data), err)
+	}
+
+	go ws.ReadMessage() // process pongs
+	select {
+	case <-pings:
+	case <-time.After(time.Second):
+		t.Fatal("no keep-alive ping received")
+	}
+
+	client.Config.AccessAppID = "other"
+	if _, err := client.DialWebSocket(context.Background(), "/feed", nil, nil); err == nil {
+		t.Fatal("expected handshake error")
+	} else if srvErr, ok := err.(*ServiceError); !ok || srvErr.Code != 401 {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestWebSocketKeepAliveSettings(t *testing.T) {
+	server := httptest.NewServer(webSocketHandler(t, func(r *bufio.Reader, w *bufio.Writer) {
+		// messages only, never a pong
+		for i := 0; i < 6; i++ {
+			time.Sleep(30 * time.Millisecond)
+			w.Write(serverFrame(true, 0x1, []byte("tick")))
+			w.Flush()
+		}
+		readClientFrame(r)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// messages extend the read deadline like pongs do
+	client.Config.WebSocketPingInterval = 0
+	client.Config.WebSocketPongWait = 100 * time.Millisecond
+	ws, err := client.DialWebSocket(context.Background(), "/ticks", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if _, _, err := ws.ReadMessage(); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+	}
+	ws.Close()
+
+	// zero disables pings and the read deadline
+	client.Config.WebSocketPongWait = 0
+	ws, err = client.DialWebSocket(context.Background(), "/ticks", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if _, data, err := ws.ReadMessage(); err != nil || string(data) != "tick" {
+		t.Fatalf("ReadMessage = %q, %v", data, err)
+	}
+}
+
+func TestWebSocketRFCExamples(t *testing.T) {
+	// Sec-WebSocket-Accept of RFC 6455 section 1.3
+	if accept := webSocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept %q", accept)
+	}
+
+	pong := make(chan []byte, 1)
+	server := httptest.NewServer(webSocketHandler(t, func(r *bufio.Reader, w *bufio.Writer) {
+		// the frames of RFC 6455 section 5.7, a ping between the fragments of a message
+		w.Write([]byte{0x81, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f})
+		w.Write([]byte{0x01, 0x03, 0x48, 0x65, 0x6c})
+		w.Write([]byte{0x89, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f})
+		w.Write([]byte{0x80, 0x02, 0x6c, 0x6f})
+		w.Write(append([]byte{0x82, 0x7E, 0x01, 0x00}, bytes.Repeat([]byte{7}, 256)...))
+		w.Flush()
+		_, opcode, payload, err := readClientFrame(r)
+		if err != nil || opcode != 0xA {
+			t.Errorf("unexpected frame %x %q: %v", opcode, payload, err)
+		}
+		pong <- payload
+		readClientFrame(r)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := client.DialWebSocket(context.Background(), "/rfc", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	for _, want := range []string{"Hello", "Hello"} {
+		if messageType, data, err := ws.ReadMessage(); err != nil || messageType != WebSocketTextMessage || string(data) != want {
+			t.Fatalf("ReadMessage = %d %q, %v", messageType, data, err)
+		}
+	}
+	if messageType, data, err := ws.ReadMessage(); err != nil || messageType != WebSocketBinaryMessage || len(data) != 256 {
+		t.Fatalf("ReadMessage = %d %d bytes, %v", messageType, len(data), err)
+	}
+	if payload := <-pong; string(payload) != "Hello" {
+		t.Fatalf("unexpected pong %q", payload)
+	}
+}
+
+func TestWebSocketProtocolFailures(t *testing.T) {
+	frames := map[string][]byte{
+		// a frame announcing 1TB, only its header is sent
+		"/huge": {0x82, 0x7F, 0, 0, 0x01, 0, 0, 0, 0, 0},
+		// fragments over the limit together
+		"/fragments": append(serverFrame(false, 0x2, make([]byte, 60)), serverFrame(true, 0x0, make([]byte, 60))...),
+		// servers must not mask
+		"/masked": {0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58},
+	}
+	closes := make(chan int, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSocketHandler(t, func(r2 *bufio.Reader, w2 *bufio.Writer) {
+			w2.Write(frames[r.URL.Path])
+			w2.Flush()
+			_, opcode, payload, err := readClientFrame(r2)
+			if err != nil || opcode != 0x8 || len(payload) < 2 {
+				t.Errorf("expected a close frame, got %x %q: %v", opcode, payload, err)
+				closes <- 0
+				return
+			}
+			closes <- int(binary.BigEndian.Uint16(payload))
+		})(w, r)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Config.WebSocketMaxMessageSize = 100
+	for path, code := range map[string]int{"/huge": 1009, "/fragments": 1009, "/masked": 1002} {
+		ws, err := client.DialWebSocket(context.Background(), path, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = ws.ReadMessage()
+		var closeErr *WebSocketCloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != code {
+			t.Fatalf("%s: unexpected error %v", path, err)
+		}
+		if sent := <-closes; sent != code {
+			t.Fatalf("%s: client closed with %d, want %d", path, sent, code)
+		}
+		ws.Close()
+	}
+}